@@ -4,6 +4,8 @@ package main
 import (
 	"context"
 	"log"
+	"os/signal"
+	"syscall"
 
 	"github.com/Alkush-Pipania/source-service/config"
 	"github.com/Alkush-Pipania/source-service/internal/app"
@@ -32,16 +34,33 @@ func main() {
 	}
 	defer conn.Close()
 
-	ch, err := rabbitmq.NewChannel(conn.Conn, rabbitmq.ConsumerConfig{
+	ch, err := rabbitmq.NewChannel(conn, rabbitmq.ConsumerConfig{
 		Exchange:     cfg.Exchange,
 		ExchangeType: cfg.ExchangeType,
 		Queue:        cfg.QueueName,
 		RoutingKey:   cfg.RoutingKey,
+
+		ControlRoutingKey: cfg.ControlRoutingKey,
+
+		MaxRetries:     cfg.MaxRetries,
+		RetryBaseDelay: cfg.RetryBaseDelay,
+
+		Concurrency: cfg.WorkerConcurrency,
+		Prefetch:    cfg.PrefetchCount,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Publisher for source lifecycle events
+	events, err := rabbitmq.NewPublisher(conn, rabbitmq.PublisherConfig{
+		Exchange:     cfg.Exchange,
+		ExchangeType: cfg.ExchangeType,
 	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer ch.Close()
+	defer events.Close()
 
 	// Initialize S3/DigitalOcean Spaces client
 	s3Client, err := s3.NewClient(ctx, s3.ClientConfig{
@@ -88,24 +107,38 @@ func main() {
 		Pinecone:   pineconeClient,
 		LlamaParse: llamaParseClient,
 		S3:         s3Client,
+		Publisher:  events,
 	}
 
 	// Initialize container with all services
-	container, cleanup, err := app.NewContainer(ctx, cfg, q, clients)
+	container, cleanup, err := app.NewContainer(ctx, cfg, dbConn, q, clients)
 	if err != nil {
 		log.Fatalf("Failed to initialize app: %v", err)
 	}
 	defer cleanup()
 
-	// Create worker with services and db
-	w := worker.NewWorker(container.Services, q)
+	// Create worker with services, handler registry and db
+	w := worker.NewWorker(container.Services, container.Registry, q, events, cfg.MaxRetries)
 
 	// Start consumer
-	err = ch.Start(w.HandleMessage)
+	err = ch.Start(w.HandleMessage, w.MessageKey)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Consumer started")
-	select {} // block forever
+
+	// Block until SIGINT/SIGTERM, then drain in-flight jobs
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+
+	log.Printf("Shutting down, waiting up to %s for in-flight jobs", cfg.ShutdownTimeout)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelDrain()
+
+	if err := ch.Shutdown(drainCtx); err != nil {
+		log.Printf("Error during consumer shutdown: %v", err)
+	}
+	log.Println("Shutdown complete")
 }
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -17,6 +18,55 @@ type Config struct {
 	ExchangeType string
 	RoutingKey   string
 
+	// Routing key of cancel messages, consumed from a queue of their own so
+	// they don't wait behind the jobs they stop
+	ControlRoutingKey string
+
+	// Retries
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+
+	// Worker pool
+	WorkerConcurrency int
+	PrefetchCount     int
+	ShutdownTimeout   time.Duration
+
+	// Processing budget per job, by source type
+	LinkJobTimeout time.Duration
+	NoteJobTimeout time.Duration
+	DocJobTimeout  time.Duration
+
+	// Chunking, sizes in tokens and splitter names by source type
+	// ("recursive", "markdown", "semantic" or "character")
+	ChunkSize    int
+	ChunkOverlap int
+	LinkSplitter string
+	NoteSplitter string
+	DocSplitter  string
+
+	// Size in tokens of the parent sections child chunks are split from, 0 (the default) disables them
+	ParentChunkSize int
+
+	// Plain text and CSV uploads, and the markdown parsed from PDFs, slides and
+	// Word files, larger than this are split as they are read instead of being
+	// loaded into memory, 0 never streams
+	DocStreamThresholdMB int
+
+	// How CSV rows are rendered in chunks: "table" (markdown) or "kv" (column=value lines)
+	CSVFormat string
+
+	// Near-duplicate chunk removal: largest simhash distance dropped (negative
+	// disables it) and whether the user's other sources are compared against
+	DedupeMaxDistance   int
+	DedupeAcrossSources bool
+
+	// Semantic chunking, used by source types set to "semantic" and by
+	// every source of the listed users
+	SemanticMinTokens            int
+	SemanticMaxTokens            int
+	SemanticBreakpointPercentile float64
+	SemanticUsers                []string
+
 	// Database
 	DbUrl string
 	Env   string
@@ -54,6 +104,43 @@ func LoadEnv() *Config {
 		ExchangeType: getkey("EXCHANGETYPE", "direct"),
 		RoutingKey:   getkey("ROUTINGKEY", "source.process"),
 
+		ControlRoutingKey: getkey("CONTROL_ROUTINGKEY", "source.control"),
+
+		// Retries
+		MaxRetries:     getEnvValue(os.Getenv("MAX_RETRIES"), 5),
+		RetryBaseDelay: time.Duration(getEnvValue(os.Getenv("RETRY_BASE_DELAY_SECONDS"), 5)) * time.Second,
+
+		// Worker pool
+		WorkerConcurrency: getEnvValue(os.Getenv("WORKER_CONCURRENCY"), 4),
+		PrefetchCount:     getEnvValue(os.Getenv("PREFETCH_COUNT"), 8),
+		ShutdownTimeout:   time.Duration(getEnvValue(os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"), 30)) * time.Second,
+
+		// Job budgets
+		LinkJobTimeout: time.Duration(getEnvValue(os.Getenv("LINK_JOB_TIMEOUT_SECONDS"), 120)) * time.Second,
+		NoteJobTimeout: time.Duration(getEnvValue(os.Getenv("NOTE_JOB_TIMEOUT_SECONDS"), 60)) * time.Second,
+		DocJobTimeout:  time.Duration(getEnvValue(os.Getenv("DOC_JOB_TIMEOUT_SECONDS"), 600)) * time.Second,
+
+		// Chunking
+		ChunkSize:    getEnvValue(os.Getenv("CHUNK_SIZE_TOKENS"), 250),
+		ChunkOverlap: getEnvValue(os.Getenv("CHUNK_OVERLAP_TOKENS"), 50),
+		LinkSplitter: getkey("LINK_SPLITTER", "recursive"),
+		NoteSplitter: getkey("NOTE_SPLITTER", "recursive"),
+		DocSplitter:  getkey("DOC_SPLITTER", "markdown"),
+
+		ParentChunkSize: getEnvValue(os.Getenv("PARENT_CHUNK_SIZE_TOKENS"), 0),
+		CSVFormat:       getkey("CSV_FORMAT", "table"),
+
+		DocStreamThresholdMB: getEnvValue(os.Getenv("DOC_STREAM_THRESHOLD_MB"), 20),
+
+		DedupeMaxDistance:   getEnvValue(os.Getenv("DEDUPE_MAX_DISTANCE"), 3),
+		DedupeAcrossSources: getkey("DEDUPE_ACROSS_SOURCES", "false") == "true",
+
+		// Semantic chunking
+		SemanticMinTokens:            getEnvValue(os.Getenv("SEMANTIC_MIN_TOKENS"), 50),
+		SemanticMaxTokens:            getEnvValue(os.Getenv("SEMANTIC_MAX_TOKENS"), 500),
+		SemanticBreakpointPercentile: getEnvFloat(os.Getenv("SEMANTIC_BREAKPOINT_PERCENTILE"), 10),
+		SemanticUsers:                getList("SEMANTIC_USERS"),
+
 		// Database
 		DbUrl: getkey("DB_URL", ""),
 		Env:   getkey("ENV", "development"),
@@ -86,6 +173,17 @@ func getkey(key string, fallback string) string {
 	return os.Getenv(key)
 }
 
+// getList splits a comma separated variable, skipping empty entries
+func getList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 func getEnvValue(s string, fallback int) int {
 	if s == "" {
 		return fallback
@@ -98,3 +196,16 @@ func getEnvValue(s string, fallback int) int {
 	}
 	return value
 }
+
+func getEnvFloat(s string, fallback float64) float64 {
+	if s == "" {
+		return fallback
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+
+	if err != nil {
+		return fallback
+	}
+	return value
+}
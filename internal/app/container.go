@@ -2,16 +2,24 @@ package app
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/Alkush-Pipania/source-service/config"
+	"github.com/Alkush-Pipania/source-service/internal/modules"
 	"github.com/Alkush-Pipania/source-service/internal/modules/docs"
+	"github.com/Alkush-Pipania/source-service/internal/modules/indexing"
+	"github.com/Alkush-Pipania/source-service/internal/modules/jobs"
 	"github.com/Alkush-Pipania/source-service/internal/modules/links"
 	"github.com/Alkush-Pipania/source-service/internal/modules/notes"
+	"github.com/Alkush-Pipania/source-service/internal/modules/sources"
 	"github.com/Alkush-Pipania/source-service/pkg/client/gemini"
 	"github.com/Alkush-Pipania/source-service/pkg/client/lamaparse"
 	"github.com/Alkush-Pipania/source-service/pkg/client/pinecone"
 	"github.com/Alkush-Pipania/source-service/pkg/client/s3"
 	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/Alkush-Pipania/source-service/pkg/rabbitmq"
+	"github.com/Alkush-Pipania/source-service/pkg/utils"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Clients holds all external API clients
@@ -20,45 +28,128 @@ type Clients struct {
 	Pinecone   *pinecone.Client
 	LlamaParse *lamaparse.Client
 	S3         *s3.Client
+	Publisher  *rabbitmq.Publisher
 }
 
-// Services holds all module services
+// Services holds the module services that work across source types.
+// Per-type services are reached through the handler registry instead.
 type Services struct {
-	Links *links.Service
-	Notes *notes.Service
-	Docs  *docs.Service
+	Sources *sources.Service
+	Jobs    *jobs.Service
 }
 
 type Container struct {
 	DB       *db.Queries
 	Clients  *Clients
 	Services *Services
+	Registry *modules.Registry
 }
 
-func NewContainer(ctx context.Context, cfg *config.Config, queries *db.Queries, clients *Clients) (*Container, func(), error) {
+func NewContainer(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool, queries *db.Queries, clients *Clients) (*Container, func(), error) {
 	// Initialize repositories
 	linksRepo := links.NewRepository(queries)
 	notesRepo := notes.NewRepository(queries)
-	docsRepo := docs.NewRepository(queries)
+	docsRepo := docs.NewRepository(queries, pool)
+	sourcesRepo := sources.NewRepository(queries)
+	jobsRepo := jobs.NewRepository(queries)
+	indexingRepo := indexing.NewRepository(queries)
 
 	// Initialize processors
 	linkProcessor := links.NewLinkProcessor()
-	docProcessor := docs.NewDocProcessor(clients.S3, clients.LlamaParse)
+	docProcessor := docs.NewDocProcessor(clients.S3, clients.LlamaParse, int64(cfg.DocStreamThresholdMB)<<20)
+
+	// Splitters per source type, all sized in tokens
+	tokenizer := utils.ApproxTokenizer{}
+	semantic := &utils.SemanticSplitter{
+		Embedder:   indexing.NewGeminiEmbedder(clients.Gemini),
+		Tokenizer:  tokenizer,
+		MinSize:    cfg.SemanticMinTokens,
+		MaxSize:    cfg.SemanticMaxTokens,
+		Percentile: cfg.SemanticBreakpointPercentile,
+	}
+
+	// Users opted into semantic chunking get it for every source type
+	byUser := make(map[string]utils.Splitter, len(cfg.SemanticUsers))
+	for _, userID := range cfg.SemanticUsers {
+		byUser[userID] = semantic
+	}
+
+	if cfg.ParentChunkSize > 0 && cfg.ParentChunkSize <= cfg.ChunkSize {
+		return nil, nil, fmt.Errorf("parent chunk size (%d) must be larger than chunk size (%d)", cfg.ParentChunkSize, cfg.ChunkSize)
+	}
+
+	// newChunker pairs the named splitter with a parent splitter of the same
+	// kind, semantic parents being split recursively
+	newChunker := func(name string) (*indexing.Chunker, error) {
+		splitter := utils.Splitter(semantic)
+		if name != utils.SplitterSemantic {
+			var err error
+			splitter, err = utils.NewSplitter(name, cfg.ChunkSize, cfg.ChunkOverlap, tokenizer)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var parent utils.Splitter
+		if cfg.ParentChunkSize > 0 {
+			parentName := name
+			if parentName == utils.SplitterSemantic {
+				parentName = utils.SplitterRecursive
+			}
+			var err error
+			parent, err = utils.NewSplitter(parentName, cfg.ParentChunkSize, 0, tokenizer)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return indexing.NewChunker(splitter, parent, byUser), nil
+	}
+
+	linkChunker, err := newChunker(cfg.LinkSplitter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("link splitter: %w", err)
+	}
+	noteChunker, err := newChunker(cfg.NoteSplitter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("note splitter: %w", err)
+	}
+	docChunker, err := newChunker(cfg.DocSplitter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("doc splitter: %w", err)
+	}
+	if cfg.CSVFormat != utils.CSVFormatTable && cfg.CSVFormat != utils.CSVFormatKeyValue {
+		return nil, nil, fmt.Errorf("unknown csv format: %s", cfg.CSVFormat)
+	}
+	tableChunker := indexing.NewTableChunker(&utils.CSVSplitter{Size: cfg.ChunkSize, Format: cfg.CSVFormat, Tokenizer: tokenizer})
+
+	// Shared embed + upsert pipeline
+	indexer := indexing.NewIndexer(clients.Gemini, clients.Pinecone, indexingRepo, tokenizer, indexing.DedupeOptions{
+		MaxDistance:   cfg.DedupeMaxDistance,
+		AcrossSources: cfg.DedupeAcrossSources,
+	})
 
 	// Initialize services
-	linksService := links.NewService(linksRepo, linkProcessor, clients.Gemini, clients.Pinecone, clients.S3)
-	notesService := notes.NewService(notesRepo, clients.Gemini, clients.Pinecone)
-	docsService := docs.NewService(docsRepo, docProcessor)
+	linksService := links.NewService(linksRepo, linkProcessor, linkChunker, indexer, clients.S3)
+	notesService := notes.NewService(notesRepo, noteChunker, indexer)
+	docsService := docs.NewService(docsRepo, docProcessor, docChunker, tableChunker, indexer)
+	sourcesService := sources.NewService(sourcesRepo, clients.Pinecone, clients.S3, clients.Publisher, cfg.RoutingKey)
+	jobsService := jobs.NewService(jobsRepo)
 
 	services := &Services{
-		Links: linksService,
-		Notes: notesService,
-		Docs:  docsService,
+		Sources: sourcesService,
+		Jobs:    jobsService,
 	}
 
+	// Register a handler per source type, new modules plug in here
+	registry := modules.NewRegistry()
+	registry.Register(modules.WithTimeout(modules.HandlerFunc(linksService.ProcessLink), cfg.LinkJobTimeout), "link")
+	registry.Register(modules.WithTimeout(modules.HandlerFunc(notesService.ProcessNote), cfg.NoteJobTimeout), "note")
+	registry.Register(modules.WithTimeout(modules.HandlerFunc(docsService.ProcessDoc), cfg.DocJobTimeout), "pdf", "ppt", "doc")
+
 	return &Container{
 		DB:       queries,
 		Clients:  clients,
 		Services: services,
+		Registry: registry,
 	}, func() {}, nil
 }
@@ -1,11 +1,14 @@
 package docs
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/Alkush-Pipania/source-service/internal/modules"
 	"github.com/Alkush-Pipania/source-service/pkg/client/lamaparse"
@@ -15,45 +18,103 @@ import (
 type DocProcessor struct {
 	s3        *s3.Client
 	lamaparse *lamaparse.Client
+
+	// Plain text files, and parsed markdown, larger than this many bytes are streamed, 0 never streams
+	streamAbove int64
 }
 
-func NewDocProcessor(s3 *s3.Client, lp *lamaparse.Client) *DocProcessor {
+func NewDocProcessor(s3 *s3.Client, lp *lamaparse.Client, streamAbove int64) *DocProcessor {
 	return &DocProcessor{
-		s3:        s3,
-		lamaparse: lp,
+		s3:          s3,
+		lamaparse:   lp,
+		streamAbove: streamAbove,
 	}
 }
 
+// tempFile is a downloaded or parsed file that is removed once closed
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
 func (p *DocProcessor) Process(ctx context.Context, job modules.SourceJob) (*modules.ProcessedContent, error) {
 	if job.S3Bucket == "" || job.S3Key == "" {
-		return nil, fmt.Errorf("missing s3 bucket or key")
+		return nil, modules.Permanent(fmt.Errorf("missing s3 bucket or key"))
 	}
 
 	// 1. Download file from S3 to Temp
+	job.EnterStage(ctx, modules.StageFetch)
 	tempPath, err := p.s3.DownloadToTemp(ctx, job.S3Bucket, job.S3Key)
 	if err != nil {
 		return nil, err
 	}
-	defer os.Remove(tempPath) // Cleanup temp file when done
+	streamed := false
+	defer func() {
+		if !streamed {
+			os.Remove(tempPath) // Cleanup temp file when done
+		}
+	}()
 
 	// 2. Determine File Type
 	ext := strings.ToLower(filepath.Ext(job.S3Key))
 	var contentText string
+	var pageStarts []int
 
 	// 3. Process based on type
+	job.EnterStage(ctx, modules.StageParse)
 	switch ext {
 	case ".pdf", ".ppt", ".pptx", ".doc", ".docx":
 		// Use LamaParse for complex docs
 		if p.lamaparse == nil {
 			return nil, fmt.Errorf("lamaparse client not configured")
 		}
-		contentText, err = p.lamaparse.ParseFile(ctx, tempPath)
+		// Pages are written out as they are parsed, long documents are then streamed from the file
+		parsed, starts, err := p.parsePages(ctx, tempPath)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := parsed.Stat(); err == nil && p.streamAbove > 0 && info.Size() > p.streamAbove {
+			return &modules.ProcessedContent{
+				Title:      filepath.Base(job.S3Key),
+				Body:       parsed,
+				PageStarts: starts,
+				Metadata:   docMetadata(job, ext),
+			}, nil
+		}
+		text, err := io.ReadAll(parsed)
+		parsed.Close()
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse doc: %w", err)
+			return nil, fmt.Errorf("failed to read parsed doc: %w", err)
+		}
+		contentText, pageStarts = string(text), starts
+
+	case ".txt", ".md", ".csv", ".tsv":
+		// Files too large to hold in memory are handed over open, to be read as they are split
+		if p.streamAbove > 0 {
+			info, err := os.Stat(tempPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat local file: %w", err)
+			}
+			if info.Size() > p.streamAbove {
+				file, err := os.Open(tempPath)
+				if err != nil {
+					return nil, fmt.Errorf("failed to open local file: %w", err)
+				}
+				streamed = true
+				return &modules.ProcessedContent{
+					Title:    filepath.Base(job.S3Key),
+					Body:     tempFile{file},
+					Metadata: docMetadata(job, ext),
+				}, nil
+			}
 		}
 
-	case ".txt", ".md", ".csv":
-		// Read plain text files directly
+		// Read plain text files directly, tables are kept raw and split by rows later
 		bytes, err := os.ReadFile(tempPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read local file: %w", err)
@@ -61,18 +122,71 @@ func (p *DocProcessor) Process(ctx context.Context, job modules.SourceJob) (*mod
 		contentText = string(bytes)
 
 	default:
-		return nil, fmt.Errorf("unsupported file extension: %s", ext)
+		return nil, modules.Permanent(fmt.Errorf("unsupported file extension: %s", ext))
 	}
 
 	// 4. Return result
 	return &modules.ProcessedContent{
-		Title: filepath.Base(job.S3Key), // Simple title, can be improved
-		Text:  contentText,
-		Metadata: map[string]interface{}{
-			"s3_key":    job.S3Key,
-			"s3_bucket": job.S3Bucket,
-			"file_type": ext,
-			"source":    "s3_document",
-		},
+		Title:      filepath.Base(job.S3Key), // Simple title, can be improved
+		Text:       contentText,
+		PageStarts: pageStarts,
+		Metadata:   docMetadata(job, ext),
 	}, nil
 }
+
+func docMetadata(job modules.SourceJob, ext string) map[string]interface{} {
+	return map[string]interface{}{
+		"s3_key":    job.S3Key,
+		"s3_bucket": job.S3Bucket,
+		"file_type": ext,
+		"source":    "s3_document",
+	}
+}
+
+// parsePages parses the document with LlamaParse and writes the pages'
+// markdown, separated by blank lines, to a temp file as they arrive. Returns
+// the file rewound to its start and the character offset each page starts at.
+func (p *DocProcessor) parsePages(ctx context.Context, path string) (tempFile, []int, error) {
+	file, err := os.CreateTemp("", "parsed-*.md")
+	if err != nil {
+		return tempFile{}, nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	parsed := tempFile{file}
+
+	out := bufio.NewWriter(file)
+	var starts []int
+	offset := 0
+	err = p.lamaparse.ParseFilePagesFunc(ctx, path, func(page lamaparse.Page) error {
+		if len(starts) > 0 {
+			out.WriteString("\n\n")
+			offset += 2
+		}
+		md := strings.TrimSpace(page.Markdown)
+		starts = append(starts, offset)
+		offset += utf8.RuneCountInString(md)
+		_, err := out.WriteString(md)
+		return err
+	})
+	if err != nil {
+		parsed.Close()
+		return tempFile{}, nil, fmt.Errorf("failed to parse doc: %w", err)
+	}
+
+	if err := out.Flush(); err != nil {
+		parsed.Close()
+		return tempFile{}, nil, fmt.Errorf("failed to write parsed doc: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		parsed.Close()
+		return tempFile{}, nil, fmt.Errorf("failed to rewind parsed doc: %w", err)
+	}
+	return parsed, starts, nil
+}
+
+// pageUnit names what a page of the file type is called in chunk metadata
+func pageUnit(fileType interface{}) string {
+	if fileType == ".ppt" || fileType == ".pptx" {
+		return "slide"
+	}
+	return "page"
+}
@@ -2,41 +2,174 @@ package docs
 
 import (
 	"context"
+	"errors"
+	"io"
+	"unicode/utf8"
 
 	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/Alkush-Pipania/source-service/pkg/utils"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// contentPieceSize is how many bytes of streamed text are appended to the stored content at a time
+const contentPieceSize = 4 << 20
+
 // Repository defines the interface for document-related DB operations
 type Repository interface {
-	// SaveContent stores the extracted text from the PDF/PPT
-	SaveContent(ctx context.Context, sourceID pgtype.UUID, content string) error
-	
+	// SaveContent stores the extracted text from the PDF/PPT and where its pages start
+	SaveContent(ctx context.Context, sourceID pgtype.UUID, content string, pageStarts []int) error
+
+	// SaveContentFrom is SaveContent for text too large to hold in memory, read
+	// from r. hash must be the utils.ContentHash of the text.
+	SaveContentFrom(ctx context.Context, sourceID pgtype.UUID, r io.Reader, hash string, pageStarts []int) error
+
+	// GetContent returns the stored text and page starts of the given version, or
+	// of the current version when version is 0. pgx.ErrNoRows if there is none.
+	GetContent(ctx context.Context, sourceID pgtype.UUID, version int) (string, []int, error)
+
 	// UpdateStatus updates the processing status (e.g., 'processing', 'indexed', 'failed')
 	UpdateStatus(ctx context.Context, sourceID pgtype.UUID, status db.SourceStatus) error
+
+	// UpdateContentHash stores the hash of the content that was just indexed
+	UpdateContentHash(ctx context.Context, sourceID pgtype.UUID, hash string) error
 }
 
 type repository struct {
-	q *db.Queries
+	q    *db.Queries
+	pool *pgxpool.Pool
 }
 
-func NewRepository(q *db.Queries) Repository {
-	return &repository{q: q}
+func NewRepository(q *db.Queries, pool *pgxpool.Pool) Repository {
+	return &repository{q: q, pool: pool}
 }
 
 // SaveContent calls the CreateSourceContent SQL query
-func (r *repository) SaveContent(ctx context.Context, sourceID pgtype.UUID, content string) error {
+func (r *repository) SaveContent(ctx context.Context, sourceID pgtype.UUID, content string, pageStarts []int) error {
 	// Note: We are using the same table 'source_contents' as links
+	var starts []int32
+	for _, start := range pageStarts {
+		starts = append(starts, int32(start))
+	}
+
 	return r.q.CreateSourceContent(ctx, db.CreateSourceContentParams{
 		SourceID:    sourceID,
 		ContentText: content,
+		ContentHash: utils.ContentHash(content),
+		PageStarts:  starts,
 	})
 }
 
+// SaveContentFrom calls the CreateEmptySourceContent SQL query, then
+// AppendSourceContent for every contentPieceSize bytes of text, in one
+// transaction so the row is never seen half written
+func (r *repository) SaveContentFrom(ctx context.Context, sourceID pgtype.UUID, text io.Reader, hash string, pageStarts []int) error {
+	var starts []int32
+	for _, start := range pageStarts {
+		starts = append(starts, int32(start))
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	q := r.q.WithTx(tx)
+
+	id, err := q.CreateEmptySourceContent(ctx, db.CreateEmptySourceContentParams{
+		SourceID:    sourceID,
+		ContentHash: hash,
+		PageStarts:  starts,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Already stored as the current version
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, contentPieceSize)
+	held := 0
+	for {
+		n, readErr := io.ReadFull(text, buf[held:])
+		n += held
+		end := n
+		if readErr == nil {
+			// A rune cut in half waits for the next piece, Postgres only takes valid UTF-8
+			end = completeRunes(buf[:n])
+		}
+
+		if end > 0 {
+			if err := q.AppendSourceContent(ctx, db.AppendSourceContentParams{
+				Piece: string(buf[:end]),
+				ID:    id,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+		held = copy(buf, buf[end:n])
+	}
+
+	return tx.Commit(ctx)
+}
+
+// completeRunes returns the length of b without a trailing incomplete rune
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
+
+// GetContent calls the GetSourceContentByVersion or GetLatestSourceContent SQL query
+func (r *repository) GetContent(ctx context.Context, sourceID pgtype.UUID, version int) (string, []int, error) {
+	var content db.SourceContent
+	var err error
+	if version > 0 {
+		content, err = r.q.GetSourceContentByVersion(ctx, db.GetSourceContentByVersionParams{
+			SourceID: sourceID,
+			Version:  int32(version),
+		})
+	} else {
+		content, err = r.q.GetLatestSourceContent(ctx, sourceID)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	var starts []int
+	for _, start := range content.PageStarts {
+		starts = append(starts, int(start))
+	}
+	return content.ContentText, starts, nil
+}
+
 // UpdateStatus calls the UpdateSourceStatus SQL query
 func (r *repository) UpdateStatus(ctx context.Context, sourceID pgtype.UUID, status db.SourceStatus) error {
 	return r.q.UpdateSourceStatus(ctx, db.UpdateSourceStatusParams{
 		ID:     sourceID,
 		Status: status,
 	})
-}
\ No newline at end of file
+}
+
+// UpdateContentHash calls the UpdateSourceContentHash SQL query
+func (r *repository) UpdateContentHash(ctx context.Context, sourceID pgtype.UUID, hash string) error {
+	return r.q.UpdateSourceContentHash(ctx, db.UpdateSourceContentHashParams{
+		ID:          sourceID,
+		ContentHash: pgtype.Text{String: hash, Valid: hash != ""},
+	})
+}
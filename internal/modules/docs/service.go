@@ -2,53 +2,195 @@ package docs
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"path/filepath"
+	"strings"
 
 	"github.com/Alkush-Pipania/source-service/internal/modules"
+	"github.com/Alkush-Pipania/source-service/internal/modules/indexing"
 	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/Alkush-Pipania/source-service/pkg/utils"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Service struct {
 	repo      Repository
 	processor *DocProcessor
+	chunker   *indexing.Chunker
+	tables    *indexing.Chunker // for CSV and TSV files
+	indexer   *indexing.Indexer
 }
 
-func NewService(repo Repository, proc *DocProcessor) *Service {
+func NewService(repo Repository, proc *DocProcessor, chunker, tables *indexing.Chunker, indexer *indexing.Indexer) *Service {
 	return &Service{
 		repo:      repo,
 		processor: proc,
+		chunker:   chunker,
+		tables:    tables,
+		indexer:   indexer,
 	}
 }
 
-func (s *Service) ProcessDoc(ctx context.Context, job modules.SourceJob) error {
+func (s *Service) ProcessDoc(ctx context.Context, job modules.SourceJob) (*modules.ProcessResult, error) {
 	log.Printf("Processing document: %s/%s", job.S3Bucket, job.S3Key)
 
 	var sourceUUID pgtype.UUID
 	if err := sourceUUID.Scan(job.SourceID); err != nil {
-		return fmt.Errorf("invalid source id: %w", err)
+		return nil, modules.Permanent(fmt.Errorf("invalid source id: %w", err))
 	}
 
-	// 1. Download & Parse
-	_, err := s.processor.Process(ctx, job)
+	// 1. Download & Parse, or reuse the stored markdown on reindex
+	content, parsed, err := s.loadContent(ctx, sourceUUID, job)
 	if err != nil {
 		log.Printf("Doc processing failed: %v", err)
-		_ = s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusFailed)
-		return err
+		return nil, err
+	}
+	if content.Body != nil {
+		defer content.Body.Close()
+	}
+
+	hash, err := contentHash(content)
+	if err != nil {
+		log.Printf("Failed to hash doc content: %v", err)
+		return nil, err
+	}
+
+	// 2. Save the parsed markdown so it never has to be parsed again, streamed
+	// files piece by piece from their temp file
+	if parsed {
+		if err := s.saveContent(ctx, sourceUUID, content, hash); err != nil {
+			log.Printf("Failed to save doc content: %v", err)
+			return nil, err
+		}
+	}
+
+	// 3. Skip re-embedding if the parsed text is the same as last time
+	if job.Unchanged(hash) {
+		log.Printf("Document %s is unchanged, skipping embedding", job.SourceID)
+		if err := s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusIndexed); err != nil {
+			return nil, err
+		}
+		return &modules.ProcessResult{Skipped: true}, nil
+	}
+
+	// 4. Chunking, tables by rows so every chunk keeps the column headers
+	job.EnterStage(ctx, modules.StageChunk)
+	chunker := s.chunker
+	if isTable(content.Metadata["file_type"]) {
+		chunker = s.tables
+	}
+
+	title := job.Title
+	if title == "" {
+		title = content.Title
+	}
+	metadata := map[string]interface{}{
+		"title":     title,
+		"type":      job.Type,
+		"file_type": content.Metadata["file_type"],
+		"s3_key":    job.S3Key,
+	}
+
+	// 5. Embed & upsert into the user's namespace, streamed files as they are split
+	var count int
+	if content.Body != nil {
+		stream := chunker.Stream(ctx, job, content.Body).SetPages(content.PageStarts, pageUnit(content.Metadata["file_type"]))
+		count, err = s.indexer.IndexStream(ctx, job, stream, metadata)
+	} else {
+		var chunks indexing.Chunks
+		chunks, err = chunker.Chunk(ctx, job, content.Text)
+		if err != nil {
+			log.Printf("Failed to chunk doc: %v", err)
+			return nil, err
+		}
+		chunks.SetPages(content.PageStarts, pageUnit(content.Metadata["file_type"]))
+
+		count, err = s.indexer.Index(ctx, job, chunks, metadata)
+	}
+	if err != nil {
+		log.Printf("Failed to index doc: %v", err)
+		return nil, err
 	}
 
-	// 2. Save Content (Skipped as per user request to remove source content)
-	// if err := s.repo.SaveContent(ctx, sourceUUID, content.Text); err != nil {
-	// 	_ = s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusFailed)
-	// 	return err
-	// }
+	if err := s.repo.UpdateContentHash(ctx, sourceUUID, hash); err != nil {
+		log.Printf("Warning: Failed to store content hash: %v", err)
+	}
 
-	// 3. Mark as Indexed (Queue for embedding in future)
+	// 6. Mark as Indexed
 	if err := s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusIndexed); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Successfully processed document: %s (%d chunks)", job.SourceID, count)
+	return &modules.ProcessResult{ChunkCount: count}, nil
+}
+
+// loadContent downloads and parses the document, or on reindex reads the
+// markdown stored by an earlier run so LlamaParse isn't paid for twice.
+// Reports whether the document was actually parsed.
+func (s *Service) loadContent(ctx context.Context, sourceUUID pgtype.UUID, job modules.SourceJob) (*modules.ProcessedContent, bool, error) {
+	if job.ReuseContent {
+		text, pageStarts, err := s.repo.GetContent(ctx, sourceUUID, job.ContentVersion)
+		if err == nil {
+			return &modules.ProcessedContent{
+				Title:      filepath.Base(job.S3Key),
+				Text:       text,
+				PageStarts: pageStarts,
+				Metadata: map[string]interface{}{
+					"file_type": strings.ToLower(filepath.Ext(job.S3Key)),
+				},
+			}, false, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, fmt.Errorf("failed to load stored content: %w", err)
+		}
+		if job.ContentVersion > 0 {
+			return nil, false, modules.Permanent(fmt.Errorf("content version %d not found", job.ContentVersion))
+		}
+		log.Printf("No stored content for document %s, parsing it again", job.SourceID)
+	}
+
+	content, err := s.processor.Process(ctx, job)
+	if err != nil {
+		return nil, false, err
+	}
+	return content, true, nil
+}
+
+// saveContent stores the document's text, a streamed body is read through and rewound
+func (s *Service) saveContent(ctx context.Context, sourceUUID pgtype.UUID, content *modules.ProcessedContent, hash string) error {
+	if content.Body == nil {
+		return s.repo.SaveContent(ctx, sourceUUID, content.Text, content.PageStarts)
+	}
+
+	if err := s.repo.SaveContentFrom(ctx, sourceUUID, content.Body, hash, content.PageStarts); err != nil {
 		return err
 	}
+	_, err := content.Body.Seek(0, io.SeekStart)
+	return err
+}
+
+// isTable reports whether the file type is a delimited text table
+func isTable(fileType interface{}) bool {
+	return fileType == ".csv" || fileType == ".tsv"
+}
+
+// contentHash hashes the document's text. A streamed body is read through and rewound.
+func contentHash(content *modules.ProcessedContent) (string, error) {
+	if content.Body == nil {
+		return utils.ContentHash(content.Text), nil
+	}
 
-	log.Printf("Successfully processed document: %s", job.SourceID)
-	return nil
+	hash, err := utils.ContentHashReader(content.Body)
+	if err != nil {
+		return "", err
+	}
+	if _, err := content.Body.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hash, nil
 }
@@ -0,0 +1,138 @@
+package indexing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/Alkush-Pipania/source-service/internal/modules"
+	"github.com/Alkush-Pipania/source-service/pkg/client/gemini"
+	"github.com/Alkush-Pipania/source-service/pkg/utils"
+)
+
+// Chunks are the pieces of one source: the chunks that get embedded and, when
+// parent chunking is on, the larger sections they were split from
+type Chunks struct {
+	Children []utils.Chunk
+	Parents  []utils.Chunk
+	// Distinct chunks are exempt from duplicate removal
+	Distinct bool
+}
+
+// Stream is Chunks for text that is still being read, the chunks are split
+// off as the indexer consumes them
+type Stream struct {
+	Chunks   iter.Seq2[utils.Chunk, error]
+	Distinct bool
+}
+
+// Chunker splits a job's text with the splitter configured for its source
+// type, or with the per-user splitter for users that have one
+type Chunker struct {
+	splitter utils.Splitter
+	parent   utils.Splitter
+	byUser   map[string]utils.Splitter
+	distinct bool
+}
+
+// NewChunker returns a Chunker that uses splitter unless byUser (keyed by user ID) has an override.
+// If parent is set, the text is first split into parent sections with it and the sections are split into children.
+func NewChunker(splitter, parent utils.Splitter, byUser map[string]utils.Splitter) *Chunker {
+	return &Chunker{
+		splitter: splitter,
+		parent:   parent,
+		byUser:   byUser,
+	}
+}
+
+// NewTableChunker returns a Chunker for row-based splitters. Its chunks get no
+// parents and are exempt from duplicate removal, since groups of rows under the
+// same header legitimately look alike.
+func NewTableChunker(splitter utils.Splitter) *Chunker {
+	return &Chunker{
+		splitter: splitter,
+		distinct: true,
+	}
+}
+
+// Chunk splits text and locates every chunk in it
+func (c *Chunker) Chunk(ctx context.Context, job modules.SourceJob, text string) (Chunks, error) {
+	splitter := c.splitter
+	if s, ok := c.byUser[job.UserID]; ok {
+		splitter = s
+	}
+
+	if c.parent == nil {
+		chunks, err := utils.SplitWith(ctx, splitter, text)
+		if err != nil {
+			return Chunks{}, fmt.Errorf("failed to chunk text: %w", err)
+		}
+		utils.Locate(text, chunks)
+		return Chunks{Children: chunks, Distinct: c.distinct}, nil
+	}
+
+	parents, children, err := utils.SplitParents(ctx, c.parent, splitter, text)
+	if err != nil {
+		return Chunks{}, fmt.Errorf("failed to chunk text: %w", err)
+	}
+	utils.Locate(text, parents)
+	utils.Locate(text, children)
+	return Chunks{Children: children, Parents: parents, Distinct: c.distinct}, nil
+}
+
+// Stream splits the text read from r as it is consumed, see utils.StreamSplit.
+// Streamed text gets no parent sections.
+func (c *Chunker) Stream(ctx context.Context, job modules.SourceJob, r io.Reader) Stream {
+	splitter := c.splitter
+	if s, ok := c.byUser[job.UserID]; ok {
+		splitter = s
+	}
+
+	return Stream{
+		Chunks:   utils.StreamSplit(ctx, splitter, r, utils.StreamWindow),
+		Distinct: c.distinct,
+	}
+}
+
+// SetPages adds the pages (or slides, per unit) every chunk spans to its metadata, see utils.SetPages
+func (c Chunks) SetPages(pageStarts []int, unit string) {
+	utils.SetPages(c.Children, pageStarts, unit)
+	utils.SetPages(c.Parents, pageStarts, unit)
+}
+
+// SetPages returns the stream with the pages (or slides, per unit) every chunk
+// spans added to its metadata as it is split, see utils.SetPages
+func (s Stream) SetPages(pageStarts []int, unit string) Stream {
+	if len(pageStarts) == 0 {
+		return s
+	}
+
+	chunks := s.Chunks
+	s.Chunks = func(yield func(utils.Chunk, error) bool) {
+		for chunk, err := range chunks {
+			if err == nil {
+				one := []utils.Chunk{chunk}
+				utils.SetPages(one, pageStarts, unit)
+				chunk = one[0]
+			}
+			if !yield(chunk, err) {
+				return
+			}
+		}
+	}
+	return s
+}
+
+// GeminiEmbedder adapts gemini.Client to utils.Embedder
+type GeminiEmbedder struct {
+	client *gemini.Client
+}
+
+func NewGeminiEmbedder(client *gemini.Client) *GeminiEmbedder {
+	return &GeminiEmbedder{client: client}
+}
+
+func (e *GeminiEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.client.GenerateEmbeddings(ctx, texts)
+}
@@ -0,0 +1,497 @@
+package indexing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Alkush-Pipania/source-service/internal/modules"
+	"github.com/Alkush-Pipania/source-service/pkg/client/gemini"
+	"github.com/Alkush-Pipania/source-service/pkg/client/pinecone"
+	"github.com/Alkush-Pipania/source-service/pkg/utils"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// upsertBatchSize keeps each upsert request well under Pinecone's 2MB limit
+const upsertBatchSize = 100
+
+// rescuedVectors bounds how many overwritten vectors one run keeps in memory
+// for chunks further down that moved to where they were
+const rescuedVectors = 1000
+
+// DedupeOptions controls the removal of near-duplicate chunks, see utils.Deduper
+type DedupeOptions struct {
+	// MaxDistance is the largest simhash Hamming distance treated as a duplicate, negative disables removal
+	MaxDistance int
+	// AcrossSources also drops chunks that duplicate one indexed from another of the user's sources
+	AcrossSources bool
+}
+
+// pendingChunk is a chunk waiting for its vector, with its manifest entry and metadata
+type pendingChunk struct {
+	chunk utils.Chunk
+	entry ManifestEntry
+	meta  map[string]interface{}
+}
+
+// Indexer embeds chunks with Gemini and upserts them into Pinecone.
+// It is shared by every source type so they all write vectors the same way.
+type Indexer struct {
+	gemini    *gemini.Client
+	pinecone  *pinecone.Client
+	repo      Repository
+	tokenizer utils.Tokenizer
+	dedupe    DedupeOptions
+}
+
+func NewIndexer(gem *gemini.Client, pine *pinecone.Client, repo Repository, tok utils.Tokenizer, dedupe DedupeOptions) *Indexer {
+	return &Indexer{
+		gemini:    gem,
+		pinecone:  pine,
+		repo:      repo,
+		tokenizer: tok,
+		dedupe:    dedupe,
+	}
+}
+
+// Index embeds the child chunks and upserts them into the job's namespace (the
+// user ID) as sourceID_chunkIndex vectors. metadata is copied onto every vector
+// next to the chunk's own fields. Parent sections, if any, are stored in
+// Postgres and every vector gets the parent_id and parent_index of its section.
+//
+// Repeated boilerplate (footers, banners, slide templates) is dropped before
+// embedding, keeping the first copy. Chunks whose text is in the source's
+// chunk manifest are not embedded again: their vector is copied over from the
+// index it had, or rewritten in place when only its metadata changed, with the
+// complete new metadata either way. Only new or changed chunks are embedded,
+// and vectors for chunk indexes that no longer exist are deleted. Chunks that
+// fail to embed don't hold up the others, but the call then fails so the source
+// is retried and its content hash isn't saved; the manifest makes the retry
+// embed only the missing chunks. Returns the number of vectors the source now has.
+func (ix *Indexer) Index(ctx context.Context, job modules.SourceJob, chunks Chunks, metadata map[string]interface{}) (int, error) {
+	var sourceUUID pgtype.UUID
+	if err := sourceUUID.Scan(job.SourceID); err != nil {
+		return 0, modules.Permanent(fmt.Errorf("invalid source id: %w", err))
+	}
+
+	// Parents go first so a retriever never sees a parent_id it can't expand
+	if err := ix.repo.SaveParents(ctx, sourceUUID, chunks.Parents); err != nil {
+		return 0, fmt.Errorf("failed to save parent chunks: %w", err)
+	}
+
+	children := func(yield func(utils.Chunk, error) bool) {
+		for _, chunk := range chunks.Children {
+			if !yield(chunk, nil) {
+				return
+			}
+		}
+	}
+	return ix.index(ctx, job, sourceUUID, children, chunks.Distinct, len(chunks.Parents) > 0, metadata)
+}
+
+// IndexStream is Index for a source that is still being read and split. Chunks
+// are embedded as they come and upserted every upsertBatchSize vectors, so
+// memory use doesn't grow with the size of the source. Streamed sources have
+// no parent sections, any stored by an earlier run are removed.
+func (ix *Indexer) IndexStream(ctx context.Context, job modules.SourceJob, stream Stream, metadata map[string]interface{}) (int, error) {
+	var sourceUUID pgtype.UUID
+	if err := sourceUUID.Scan(job.SourceID); err != nil {
+		return 0, modules.Permanent(fmt.Errorf("invalid source id: %w", err))
+	}
+
+	if err := ix.repo.SaveParents(ctx, sourceUUID, nil); err != nil {
+		return 0, fmt.Errorf("failed to remove parent chunks: %w", err)
+	}
+	return ix.index(ctx, job, sourceUUID, stream.Chunks, stream.Distinct, false, metadata)
+}
+
+func (ix *Indexer) index(ctx context.Context, job modules.SourceJob, sourceUUID pgtype.UUID, chunks iter.Seq2[utils.Chunk, error], distinct, hierarchical bool, metadata map[string]interface{}) (int, error) {
+	namespace, sourceID := job.UserID, job.SourceID
+
+	manifest, err := ix.repo.Manifest(ctx, sourceUUID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load chunk manifest: %w", err)
+	}
+
+	deduper, err := ix.newDeduper(ctx, job, sourceUUID, distinct)
+	if err != nil {
+		return 0, err
+	}
+
+	// The text hash each vector holds and the positions holding each hash, so
+	// a chunk that moved gets the vector it had copied instead of embedded
+	held := make(map[int]string, len(manifest))
+	holders := make(map[string][]int, len(manifest))
+	for idx, entry := range manifest {
+		held[idx] = entry.TextHash
+		holders[entry.TextHash] = append(holders[entry.TextHash], idx)
+	}
+	holder := func(hash string) (int, bool) {
+		for _, idx := range holders[hash] {
+			if held[idx] == hash {
+				return idx, true
+			}
+		}
+		return 0, false
+	}
+	rescued := newVectorCache(rescuedVectors)
+	copyable := func(hash string) bool {
+		if _, ok := rescued.get(hash); ok {
+			return true
+		}
+		_, ok := holder(hash)
+		return ok
+	}
+
+	var vectors []pinecone.Vector
+	var pending, copies []pendingChunk
+	var lastErr error
+	written := make(map[int]ManifestEntry)
+	live := make(map[int]bool)
+	total, embedded, copied, updated, failed, duplicates := 0, 0, 0, 0, 0, 0
+	upserting := false
+
+	// flush upserts the pending vectors and copies, and records them all in the
+	// manifest. A copy reuses the values of the vector holding the same text,
+	// which may be its own when only the metadata changed, and gets its
+	// complete new metadata. Copies whose vector is gone are queued to be
+	// embedded instead.
+	flush := func() error {
+		if len(vectors) == 0 && len(copies) == 0 {
+			return nil
+		}
+		if !upserting {
+			job.EnterStage(ctx, modules.StageUpsert)
+			upserting = true
+		}
+
+		// Vectors to copy are fetched before anything is overwritten, and so
+		// are the ones about to be, a chunk further down may have moved there
+		fetch := make(map[string]string)
+		for _, c := range copies {
+			if _, ok := rescued.get(c.entry.TextHash); !ok {
+				if idx, ok := holder(c.entry.TextHash); ok {
+					fetch[modules.VectorID(sourceID, idx)] = c.entry.TextHash
+				}
+			}
+		}
+		overwrite := func(idx int, hash string) {
+			if old, ok := held[idx]; ok && old != hash {
+				if _, ok := rescued.get(old); !ok {
+					fetch[modules.VectorID(sourceID, idx)] = old
+				}
+			}
+		}
+		for idx, entry := range written {
+			overwrite(idx, entry.TextHash)
+		}
+		for _, c := range copies {
+			overwrite(c.chunk.Index, c.entry.TextHash)
+		}
+
+		if len(fetch) > 0 {
+			ids := make([]string, 0, len(fetch))
+			for id := range fetch {
+				ids = append(ids, id)
+			}
+			found, err := ix.pinecone.FetchWithNamespace(ctx, namespace, ids)
+			if err != nil {
+				return err
+			}
+			for id, values := range found {
+				rescued.add(fetch[id], values)
+			}
+		}
+
+		for _, c := range copies {
+			values, ok := rescued.get(c.entry.TextHash)
+			if !ok {
+				pending = append(pending, c)
+				continue
+			}
+			vectors = append(vectors, pinecone.Vector{
+				ID:       modules.VectorID(sourceID, c.chunk.Index),
+				Values:   values,
+				Metadata: c.meta,
+			})
+			written[c.chunk.Index] = c.entry
+			if held[c.chunk.Index] == c.entry.TextHash {
+				updated++
+			} else {
+				copied++
+			}
+		}
+
+		for start := 0; start < len(vectors); start += upsertBatchSize {
+			end := min(start+upsertBatchSize, len(vectors))
+			if _, err := ix.pinecone.UpsertWithNamespace(ctx, namespace, vectors[start:end]); err != nil {
+				return err
+			}
+		}
+		if err := ix.repo.SaveManifest(ctx, sourceUUID, written); err != nil {
+			return fmt.Errorf("failed to save chunk manifest: %w", err)
+		}
+
+		for idx, entry := range written {
+			if held[idx] != entry.TextHash {
+				held[idx] = entry.TextHash
+				holders[entry.TextHash] = append(holders[entry.TextHash], idx)
+			}
+		}
+		vectors, copies = vectors[:0], copies[:0]
+		written = make(map[int]ManifestEntry)
+		return nil
+	}
+
+	// embed embeds the pending chunks and queues their vectors for the next flush
+	embed := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+
+		texts := make([]string, len(pending))
+		for i, p := range pending {
+			texts[i] = p.chunk.EmbedText()
+		}
+
+		embeddings, err := ix.gemini.GenerateEmbeddings(ctx, texts)
+		var embedErrs gemini.EmbeddingErrors
+		if err != nil && !errors.As(err, &embedErrs) {
+			return err
+		}
+
+		for i, p := range pending {
+			if err, ok := embedErrs[i]; ok {
+				log.Printf("Failed to embed chunk %d of %s: %v", p.chunk.Index, sourceID, err)
+				lastErr = err
+				failed++
+				if _, ok := manifest[p.chunk.Index]; !ok {
+					// Nothing indexed at this position yet
+					delete(live, p.chunk.Index)
+				}
+				continue
+			}
+
+			vectors = append(vectors, pinecone.Vector{
+				ID:       modules.VectorID(sourceID, p.chunk.Index),
+				Values:   embeddings[i],
+				Metadata: p.meta,
+			})
+			written[p.chunk.Index] = p.entry
+			embedded++
+		}
+
+		pending = pending[:0]
+		return nil
+	}
+
+	// 1. Generate Embeddings for new or changed chunks, gemini.MaxBatchSize per request
+	job.EnterStage(ctx, modules.StageEmbed)
+	for next, err := range chunks {
+		if err != nil {
+			return 0, fmt.Errorf("failed to chunk text: %w", err)
+		}
+
+		// Anything the embedding model would reject as too long is split further
+		for _, chunk := range utils.FitToLimit([]utils.Chunk{next}, gemini.MaxInputTokens, ix.tokenizer) {
+			if strings.TrimSpace(chunk.Text) == "" {
+				continue
+			}
+
+			var simhash uint64
+			if deduper != nil {
+				var duplicate bool
+				if simhash, duplicate = deduper.Add(chunk.Text); duplicate {
+					duplicates++
+					continue
+				}
+			}
+
+			chunk.Index = total
+			total++
+
+			meta := chunkMetadata(sourceID, chunk, hierarchical, metadata)
+			entry := ManifestEntry{TextHash: chunkHash(chunk), MetaHash: metadataHash(meta), SimHash: simhash}
+			live[chunk.Index] = true
+
+			if indexed, ok := manifest[chunk.Index]; ok && indexed.TextHash == entry.TextHash {
+				// Same embedding, but the chunk may have moved or the source been renamed
+				if indexed.MetaHash != entry.MetaHash {
+					copies = append(copies, pendingChunk{chunk: chunk, entry: entry, meta: meta})
+				}
+			} else if copyable(entry.TextHash) {
+				// Moved from another position, e.g. after text was inserted above it
+				copies = append(copies, pendingChunk{chunk: chunk, entry: entry, meta: meta})
+			} else {
+				pending = append(pending, pendingChunk{chunk: chunk, entry: entry, meta: meta})
+			}
+
+			if len(pending) >= gemini.MaxBatchSize {
+				if err := embed(); err != nil {
+					return 0, err
+				}
+			}
+			// 2. Upsert to Pinecone in batches
+			if len(vectors)+len(copies) >= upsertBatchSize {
+				if err := flush(); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+
+	// A flush hands back the copies it couldn't make, so go until nothing is left
+	for len(pending) > 0 || len(vectors) > 0 || len(copies) > 0 {
+		if err := embed(); err != nil {
+			return 0, err
+		}
+		if err := flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	// 3. Remove vectors of chunks that no longer exist, e.g. after the text got shorter
+	var stale []int
+	var staleIDs []string
+	if len(manifest) > 0 {
+		for idx := range manifest {
+			if !live[idx] {
+				stale = append(stale, idx)
+			}
+		}
+		sort.Ints(stale)
+		for _, idx := range stale {
+			staleIDs = append(staleIDs, modules.VectorID(sourceID, idx))
+		}
+	} else {
+		// Sources indexed before the manifest existed have no rows, their vectors are looked up instead
+		prefix := modules.VectorPrefix(sourceID)
+		ids, err := ix.pinecone.ListIDsByPrefix(ctx, namespace, prefix)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list existing vectors: %w", err)
+		}
+		for _, id := range ids {
+			if idx, err := strconv.Atoi(strings.TrimPrefix(id, prefix)); err != nil || !live[idx] {
+				staleIDs = append(staleIDs, id)
+			}
+		}
+	}
+
+	if len(staleIDs) > 0 {
+		if err := ix.pinecone.DeleteWithNamespace(ctx, namespace, staleIDs); err != nil {
+			return 0, fmt.Errorf("failed to delete stale vectors: %w", err)
+		}
+	}
+	if len(stale) > 0 {
+		if err := ix.repo.DeleteManifest(ctx, sourceUUID, stale); err != nil {
+			return 0, fmt.Errorf("failed to prune chunk manifest: %w", err)
+		}
+	}
+
+	log.Printf("Indexed %s: embedded %d of %d chunks, copied %d, updated metadata of %d, dropped %d duplicates, removed %d stale vectors", sourceID, embedded, len(live), copied, updated, duplicates, len(staleIDs))
+
+	job.RecordCounts(ctx, total, embedded, duplicates)
+
+	if failed > 0 {
+		return 0, fmt.Errorf("failed to embed %d of %d chunks: %w", failed, total, lastErr)
+	}
+	return len(live), nil
+}
+
+// newDeduper returns the duplicate filter for a source's chunks, or nil when
+// they are Distinct or removal is disabled. Only chunks of the same source are
+// compared unless AcrossSources is set; then chunks already indexed from the
+// user's other sources count too, and deleting one of those doesn't bring back
+// what was dropped here until this source is reindexed.
+func (ix *Indexer) newDeduper(ctx context.Context, job modules.SourceJob, sourceUUID pgtype.UUID, distinct bool) (*utils.Deduper, error) {
+	if distinct || ix.dedupe.MaxDistance < 0 {
+		return nil, nil
+	}
+
+	var seen []uint64
+	if ix.dedupe.AcrossSources {
+		var userUUID pgtype.UUID
+		if err := userUUID.Scan(job.UserID); err != nil {
+			return nil, modules.Permanent(fmt.Errorf("invalid user id: %w", err))
+		}
+
+		var err error
+		seen, err = ix.repo.SimHashes(ctx, userUUID, sourceUUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load simhashes of other sources: %w", err)
+		}
+	}
+	return utils.NewDeduper(ix.dedupe.MaxDistance, seen), nil
+}
+
+// chunkHash fingerprints the exact embedded text, context included. Offsets,
+// pages and the parent section are left to metadataHash, as they change
+// whenever text is inserted above a chunk while its embedding stays the same.
+func chunkHash(chunk utils.Chunk) string {
+	h := sha256.Sum256([]byte(chunk.EmbedText()))
+	return hex.EncodeToString(h[:])
+}
+
+// metadataHash fingerprints a vector's metadata, fmt prints maps in key order
+func metadataHash(meta map[string]interface{}) string {
+	h := sha256.New()
+	fmt.Fprint(h, meta)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func chunkMetadata(sourceID string, chunk utils.Chunk, hierarchical bool, metadata map[string]interface{}) map[string]interface{} {
+	meta := make(map[string]interface{}, len(metadata)+len(chunk.Metadata)+7)
+	for k, v := range metadata {
+		meta[k] = v
+	}
+	for k, v := range chunk.Metadata {
+		meta[k] = v
+	}
+	meta["source_id"] = sourceID
+	meta["text"] = chunk.Text
+	meta["chunk_index"] = chunk.Index
+	if chunk.Located() {
+		meta["char_start"] = chunk.Start
+		meta["char_end"] = chunk.End
+	}
+	if hierarchical {
+		meta["parent_id"] = modules.ParentID(sourceID, chunk.Parent)
+		meta["parent_index"] = chunk.Parent
+	}
+	return meta
+}
+
+// vectorCache keeps vector values by text hash, forgetting the oldest past size
+type vectorCache struct {
+	size   int
+	values map[string][]float32
+	order  []string
+}
+
+func newVectorCache(size int) *vectorCache {
+	return &vectorCache{size: size, values: make(map[string][]float32)}
+}
+
+func (c *vectorCache) get(hash string) ([]float32, bool) {
+	values, ok := c.values[hash]
+	return values, ok
+}
+
+func (c *vectorCache) add(hash string, values []float32) {
+	if _, ok := c.values[hash]; ok {
+		return
+	}
+	if len(c.order) >= c.size {
+		delete(c.values, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.values[hash] = values
+	c.order = append(c.order, hash)
+}
@@ -0,0 +1,124 @@
+package indexing
+
+import (
+	"context"
+
+	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/Alkush-Pipania/source-service/pkg/utils"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ManifestEntry is what the manifest records about one indexed chunk: a hash
+// of what was embedded, one of the metadata stored next to it and the text's
+// simhash (0 when duplicates weren't looked for)
+type ManifestEntry struct {
+	TextHash string
+	MetaHash string
+	SimHash  uint64
+}
+
+// Repository defines the DB operations on the per-source chunk manifest
+type Repository interface {
+	// Manifest returns the entry of every indexed chunk, keyed by chunk index
+	Manifest(ctx context.Context, sourceID pgtype.UUID) (map[int]ManifestEntry, error)
+
+	// SaveManifest records the hashes of freshly upserted or updated chunks
+	SaveManifest(ctx context.Context, sourceID pgtype.UUID, entries map[int]ManifestEntry) error
+
+	// DeleteManifest drops the entries for chunks whose vectors were removed
+	DeleteManifest(ctx context.Context, sourceID pgtype.UUID, indexes []int) error
+
+	// SimHashes returns the simhashes of the chunks indexed from the user's other sources
+	SimHashes(ctx context.Context, userID, sourceID pgtype.UUID) ([]uint64, error)
+
+	// SaveParents stores the source's parent sections, replacing any stored before
+	SaveParents(ctx context.Context, sourceID pgtype.UUID, parents []utils.Chunk) error
+}
+
+type repository struct {
+	q *db.Queries
+}
+
+func NewRepository(q *db.Queries) Repository {
+	return &repository{q: q}
+}
+
+func (r *repository) Manifest(ctx context.Context, sourceID pgtype.UUID) (map[int]ManifestEntry, error) {
+	rows, err := r.q.ListSourceChunkManifests(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := make(map[int]ManifestEntry, len(rows))
+	for _, row := range rows {
+		manifest[int(row.ChunkIndex)] = ManifestEntry{
+			TextHash: row.TextHash,
+			MetaHash: row.MetaHash,
+			SimHash:  uint64(row.Simhash.Int64),
+		}
+	}
+	return manifest, nil
+}
+
+func (r *repository) SaveManifest(ctx context.Context, sourceID pgtype.UUID, entries map[int]ManifestEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	params := db.UpsertSourceChunkManifestsParams{SourceID: sourceID}
+	for idx, entry := range entries {
+		params.ChunkIndexes = append(params.ChunkIndexes, int32(idx))
+		params.TextHashes = append(params.TextHashes, entry.TextHash)
+		params.MetaHashes = append(params.MetaHashes, entry.MetaHash)
+		params.Simhashes = append(params.Simhashes, int64(entry.SimHash))
+	}
+	return r.q.UpsertSourceChunkManifests(ctx, params)
+}
+
+func (r *repository) SimHashes(ctx context.Context, userID, sourceID pgtype.UUID) ([]uint64, error) {
+	rows, err := r.q.ListUserChunkSimhashes(ctx, db.ListUserChunkSimhashesParams{
+		UserID:   userID,
+		SourceID: sourceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]uint64, len(rows))
+	for i, row := range rows {
+		hashes[i] = uint64(row)
+	}
+	return hashes, nil
+}
+
+func (r *repository) DeleteManifest(ctx context.Context, sourceID pgtype.UUID, indexes []int) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	params := db.DeleteSourceChunkManifestsByIndexParams{SourceID: sourceID}
+	for _, idx := range indexes {
+		params.ChunkIndexes = append(params.ChunkIndexes, int32(idx))
+	}
+	return r.q.DeleteSourceChunkManifestsByIndex(ctx, params)
+}
+
+func (r *repository) SaveParents(ctx context.Context, sourceID pgtype.UUID, parents []utils.Chunk) error {
+	if len(parents) > 0 {
+		params := db.UpsertSourceParentChunksParams{SourceID: sourceID}
+		for _, parent := range parents {
+			params.ParentIndexes = append(params.ParentIndexes, int32(parent.Index))
+			params.Contents = append(params.Contents, parent.Text)
+			params.Contexts = append(params.Contexts, parent.Context)
+		}
+		if err := r.q.UpsertSourceParentChunks(ctx, params); err != nil {
+			return err
+		}
+	}
+
+	// Sections past the end are left over from a longer version of the source
+	return r.q.DeleteSourceParentChunksFrom(ctx, db.DeleteSourceParentChunksFromParams{
+		SourceID:    sourceID,
+		ParentIndex: int32(len(parents)),
+	})
+}
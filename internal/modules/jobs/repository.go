@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Repository defines the DB operations on processing_jobs
+type Repository interface {
+	Create(ctx context.Context, sourceID pgtype.UUID, action string, attempt int) (pgtype.UUID, error)
+	UpdateStage(ctx context.Context, jobID pgtype.UUID, stage string) error
+	UpdateCounts(ctx context.Context, jobID pgtype.UUID, chunks, vectors, duplicates int) error
+	Complete(ctx context.Context, jobID pgtype.UUID) error
+	Skip(ctx context.Context, jobID pgtype.UUID) error
+	Cancel(ctx context.Context, jobID pgtype.UUID, detail []byte) error
+	Fail(ctx context.Context, jobID pgtype.UUID, detail []byte) error
+
+	// RequestCancel records that jobs for the source published before at must stop
+	RequestCancel(ctx context.Context, sourceID pgtype.UUID, at time.Time) error
+	// CancelRequested reports whether a cancel for the source was requested after since
+	CancelRequested(ctx context.Context, sourceID pgtype.UUID, since time.Time) (bool, error)
+}
+
+type repository struct {
+	q *db.Queries
+}
+
+func NewRepository(q *db.Queries) Repository {
+	return &repository{q: q}
+}
+
+func (r *repository) Create(ctx context.Context, sourceID pgtype.UUID, action string, attempt int) (pgtype.UUID, error) {
+	return r.q.CreateProcessingJob(ctx, db.CreateProcessingJobParams{
+		SourceID: sourceID,
+		Action:   action,
+		Attempt:  int32(attempt),
+	})
+}
+
+func (r *repository) UpdateStage(ctx context.Context, jobID pgtype.UUID, stage string) error {
+	return r.q.UpdateProcessingJobStage(ctx, db.UpdateProcessingJobStageParams{
+		ID:    jobID,
+		Stage: stage,
+	})
+}
+
+func (r *repository) UpdateCounts(ctx context.Context, jobID pgtype.UUID, chunks, vectors, duplicates int) error {
+	return r.q.UpdateProcessingJobCounts(ctx, db.UpdateProcessingJobCountsParams{
+		ID:             jobID,
+		ChunkCount:     int32(chunks),
+		VectorCount:    int32(vectors),
+		DuplicateCount: int32(duplicates),
+	})
+}
+
+func (r *repository) Complete(ctx context.Context, jobID pgtype.UUID) error {
+	return r.q.CompleteProcessingJob(ctx, jobID)
+}
+
+func (r *repository) Skip(ctx context.Context, jobID pgtype.UUID) error {
+	return r.q.SkipProcessingJob(ctx, jobID)
+}
+
+func (r *repository) Cancel(ctx context.Context, jobID pgtype.UUID, detail []byte) error {
+	return r.q.CancelProcessingJob(ctx, db.CancelProcessingJobParams{
+		ID:        jobID,
+		LastError: detail,
+	})
+}
+
+func (r *repository) Fail(ctx context.Context, jobID pgtype.UUID, detail []byte) error {
+	return r.q.FailProcessingJob(ctx, db.FailProcessingJobParams{
+		ID:        jobID,
+		LastError: detail,
+	})
+}
+
+func (r *repository) RequestCancel(ctx context.Context, sourceID pgtype.UUID, at time.Time) error {
+	return r.q.RequestProcessingJobCancel(ctx, db.RequestProcessingJobCancelParams{
+		RequestedAt: pgtype.Timestamptz{Time: at, Valid: true},
+		SourceID:    sourceID,
+	})
+}
+
+func (r *repository) CancelRequested(ctx context.Context, sourceID pgtype.UUID, since time.Time) (bool, error) {
+	return r.q.HasProcessingJobCancelRequest(ctx, db.HasProcessingJobCancelRequestParams{
+		SourceID:          sourceID,
+		CancelRequestedAt: pgtype.Timestamptz{Time: since, Valid: true},
+	})
+}
@@ -0,0 +1,148 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Alkush-Pipania/source-service/internal/modules"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// finishTimeout bounds the final write of a run, which may happen after the job context is gone
+const finishTimeout = 5 * time.Second
+
+// Failure is the structured error stored on a failed job
+type Failure struct {
+	Stage     string `json:"stage,omitempty"`
+	Message   string `json:"message"`
+	Permanent bool   `json:"permanent"`
+}
+
+// Service records processing attempts in the processing_jobs table
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Start records a new attempt for the source and returns a Run that
+// implements modules.Tracker for it
+func (s *Service) Start(ctx context.Context, sourceID pgtype.UUID, action string, attempt int) (*Run, error) {
+	id, err := s.repo.Create(ctx, sourceID, action, attempt)
+	if err != nil {
+		return nil, err
+	}
+	return &Run{repo: s.repo, id: id}, nil
+}
+
+// RequestCancel records a cancel for the source's jobs published before at.
+// Running jobs notice it through CancelRequested, on whichever worker they
+// run, and queued ones check it before they start.
+func (s *Service) RequestCancel(ctx context.Context, sourceID pgtype.UUID, at time.Time) error {
+	if err := s.repo.RequestCancel(ctx, sourceID, at); err != nil {
+		return fmt.Errorf("failed to record cancel request: %w", err)
+	}
+	return nil
+}
+
+// CancelRequested reports whether a job for the source whose message was
+// published at since has been cancelled
+func (s *Service) CancelRequested(ctx context.Context, sourceID pgtype.UUID, since time.Time) (bool, error) {
+	return s.repo.CancelRequested(ctx, sourceID, since)
+}
+
+// Run tracks a single processing attempt. Write failures are logged, never
+// returned, so tracking problems can't fail the job being tracked.
+type Run struct {
+	repo Repository
+	id   pgtype.UUID
+
+	mu    sync.Mutex
+	stage modules.Stage
+}
+
+func (r *Run) EnterStage(ctx context.Context, stage modules.Stage) {
+	r.mu.Lock()
+	r.stage = stage
+	r.mu.Unlock()
+
+	if err := r.repo.UpdateStage(ctx, r.id, string(stage)); err != nil {
+		log.Printf("Warning: Failed to record stage %s for job %s: %v", stage, r.id.String(), err)
+	}
+}
+
+func (r *Run) RecordCounts(ctx context.Context, chunks, vectors, duplicates int) {
+	if err := r.repo.UpdateCounts(ctx, r.id, chunks, vectors, duplicates); err != nil {
+		log.Printf("Warning: Failed to record counts for job %s: %v", r.id.String(), err)
+	}
+}
+
+// Succeed marks the attempt as finished successfully
+func (r *Run) Succeed(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	if err := r.repo.Complete(ctx, r.id); err != nil {
+		log.Printf("Warning: Failed to complete job %s: %v", r.id.String(), err)
+	}
+}
+
+// Skip marks the attempt as finished without re-embedding unchanged content
+func (r *Run) Skip(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	if err := r.repo.Skip(ctx, r.id); err != nil {
+		log.Printf("Warning: Failed to mark job %s as skipped: %v", r.id.String(), err)
+	}
+}
+
+// Fail marks the attempt as failed, storing the error and the stage it happened in
+func (r *Run) Fail(ctx context.Context, jobErr error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	detail, err := r.failure(jobErr)
+	if err != nil {
+		log.Printf("Warning: Failed to encode failure for job %s: %v", r.id.String(), err)
+		return
+	}
+
+	if err := r.repo.Fail(ctx, r.id, detail); err != nil {
+		log.Printf("Warning: Failed to record failure for job %s: %v", r.id.String(), err)
+	}
+}
+
+// Cancel marks the attempt as cancelled, storing the stage it was stopped in
+func (r *Run) Cancel(ctx context.Context, cause error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	detail, err := r.failure(cause)
+	if err != nil {
+		log.Printf("Warning: Failed to encode cancellation for job %s: %v", r.id.String(), err)
+		return
+	}
+
+	if err := r.repo.Cancel(ctx, r.id, detail); err != nil {
+		log.Printf("Warning: Failed to record cancellation for job %s: %v", r.id.String(), err)
+	}
+}
+
+// failure encodes err together with the stage the run is in
+func (r *Run) failure(err error) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return json.Marshal(Failure{
+		Stage:     string(r.stage),
+		Message:   err.Error(),
+		Permanent: modules.IsPermanent(err),
+	})
+}
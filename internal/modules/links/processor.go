@@ -3,26 +3,37 @@ package links
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/Alkush-Pipania/source-service/internal/modules"
 	"github.com/go-shiori/go-readability"
 )
 
-type LinkProcessor struct{}
+// fetchTimeout caps a single page download, the job's own deadline may be shorter
+const fetchTimeout = 30 * time.Second
+
+type LinkProcessor struct {
+	client *http.Client
+}
 
 func NewLinkProcessor() *LinkProcessor {
-	return &LinkProcessor{}
+	return &LinkProcessor{
+		client: &http.Client{Timeout: fetchTimeout},
+	}
 }
 
 // Process visits the URL and extracts the main article text and image
 func (l *LinkProcessor) Process(ctx context.Context, job modules.SourceJob) (*modules.ProcessedContent, error) {
 	if job.OriginalURL == "" {
-		return nil, fmt.Errorf("original URL is missing")
+		return nil, modules.Permanent(fmt.Errorf("original URL is missing"))
 	}
 
-	// 1. Scrape with 30s timeout
-	article, err := readability.FromURL(job.OriginalURL, 30*time.Second)
+	// 1. Scrape, bounded by the job's context
+	job.EnterStage(ctx, modules.StageFetch)
+	article, err := l.fetch(ctx, job.OriginalURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scrape url: %w", err)
 	}
@@ -39,3 +50,34 @@ func (l *LinkProcessor) Process(ctx context.Context, job modules.SourceJob) (*mo
 		},
 	}, nil
 }
+
+// fetch downloads the page with the job's context, so a cancelled or timed out
+// job stops waiting on slow sites, and extracts the article from it
+func (l *LinkProcessor) fetch(ctx context.Context, pageURL string) (readability.Article, error) {
+	parsedURL, err := url.ParseRequestURI(pageURL)
+	if err != nil {
+		return readability.Article{}, modules.Permanent(fmt.Errorf("invalid url: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return readability.Article{}, modules.Permanent(fmt.Errorf("failed to build request: %w", err))
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return readability.Article{}, fmt.Errorf("failed to fetch the page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return readability.Article{}, fmt.Errorf("failed to fetch the page: status %d", resp.StatusCode)
+	}
+
+	// Make sure content type is HTML, anything else won't turn into an article later
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return readability.Article{}, modules.Permanent(fmt.Errorf("url is not a HTML document"))
+	}
+
+	return readability.FromReader(resp.Body, parsedURL)
+}
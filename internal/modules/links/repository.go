@@ -4,12 +4,15 @@ import (
 	"context"
 
 	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/Alkush-Pipania/source-service/pkg/utils"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Repository interface {
 	SaveContent(ctx context.Context, sourceID pgtype.UUID, content string) error
+	GetContent(ctx context.Context, sourceID pgtype.UUID, version int) (string, error)
 	UpdateStatus(ctx context.Context, sourceID pgtype.UUID, status db.SourceStatus) error
+	UpdateContentHash(ctx context.Context, sourceID pgtype.UUID, hash string) error
 	UpdateTitleAndImage(ctx context.Context, sourceID pgtype.UUID, title string, imageURL string) error
 }
 
@@ -25,9 +28,29 @@ func (r *repository) SaveContent(ctx context.Context, sourceID pgtype.UUID, cont
 	return r.q.CreateSourceContent(ctx, db.CreateSourceContentParams{
 		SourceID:    sourceID,
 		ContentText: content,
+		ContentHash: utils.ContentHash(content),
 	})
 }
 
+func (r *repository) GetContent(ctx context.Context, sourceID pgtype.UUID, version int) (string, error) {
+	if version > 0 {
+		content, err := r.q.GetSourceContentByVersion(ctx, db.GetSourceContentByVersionParams{
+			SourceID: sourceID,
+			Version:  int32(version),
+		})
+		if err != nil {
+			return "", err
+		}
+		return content.ContentText, nil
+	}
+
+	content, err := r.q.GetLatestSourceContent(ctx, sourceID)
+	if err != nil {
+		return "", err
+	}
+	return content.ContentText, nil
+}
+
 func (r *repository) UpdateStatus(ctx context.Context, sourceID pgtype.UUID, status db.SourceStatus) error {
 	return r.q.UpdateSourceStatus(ctx, db.UpdateSourceStatusParams{
 		ID:     sourceID,
@@ -35,6 +58,13 @@ func (r *repository) UpdateStatus(ctx context.Context, sourceID pgtype.UUID, sta
 	})
 }
 
+func (r *repository) UpdateContentHash(ctx context.Context, sourceID pgtype.UUID, hash string) error {
+	return r.q.UpdateSourceContentHash(ctx, db.UpdateSourceContentHashParams{
+		ID:          sourceID,
+		ContentHash: pgtype.Text{String: hash, Valid: hash != ""},
+	})
+}
+
 func (r *repository) UpdateTitleAndImage(ctx context.Context, sourceID pgtype.UUID, title string, imageURL string) error {
 	return r.q.UpdateSourceTitleAndImage(ctx, db.UpdateSourceTitleAndImageParams{
 		ID:       sourceID,
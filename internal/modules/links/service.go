@@ -2,15 +2,16 @@ package links
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/Alkush-Pipania/source-service/internal/modules"
-	"github.com/Alkush-Pipania/source-service/pkg/client/gemini"
-	"github.com/Alkush-Pipania/source-service/pkg/client/pinecone"
+	"github.com/Alkush-Pipania/source-service/internal/modules/indexing"
 	"github.com/Alkush-Pipania/source-service/pkg/client/s3"
 	"github.com/Alkush-Pipania/source-service/pkg/db"
 	"github.com/Alkush-Pipania/source-service/pkg/utils"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -21,99 +22,135 @@ const (
 type Service struct {
 	repo      Repository
 	processor *LinkProcessor
-	gemini    *gemini.Client
-	pinecone  *pinecone.Client
+	chunker   *indexing.Chunker
+	indexer   *indexing.Indexer
 	s3        *s3.Client
 }
 
+// ImagePrefix is the S3 prefix a source's link images are uploaded under
+func ImagePrefix(userID, sourceID string) string {
+	return fmt.Sprintf("%s/%s/%s", ImageKeyPrefix, userID, sourceID)
+}
+
+// ImageKey is the S3 key of a source's link image. It is the same on every
+// scrape, so a new image replaces the old one instead of orphaning it.
+func ImageKey(userID, sourceID string) string {
+	return ImagePrefix(userID, sourceID) + "/image"
+}
+
 // NewService creates a new links service
-func NewService(repo Repository, proc *LinkProcessor, gem *gemini.Client, pine *pinecone.Client, s3Client *s3.Client) *Service {
+func NewService(repo Repository, proc *LinkProcessor, chunker *indexing.Chunker, indexer *indexing.Indexer, s3Client *s3.Client) *Service {
 	return &Service{
 		repo:      repo,
 		processor: proc,
-		gemini:    gem,
-		pinecone:  pine,
+		chunker:   chunker,
+		indexer:   indexer,
 		s3:        s3Client,
 	}
 }
 
-func (s *Service) ProcessLink(ctx context.Context, job modules.SourceJob) error {
+func (s *Service) ProcessLink(ctx context.Context, job modules.SourceJob) (*modules.ProcessResult, error) {
 	log.Printf("Starting processing for link: %s", job.OriginalURL)
 
 	var sourceUUID pgtype.UUID
 	if err := sourceUUID.Scan(job.SourceID); err != nil {
-		return fmt.Errorf("invalid source id: %w", err)
+		return nil, modules.Permanent(fmt.Errorf("invalid source id: %w", err))
 	}
 
-	// 1. Scrape Content
-	content, err := s.processor.Process(ctx, job)
+	// 1. Scrape Content, or reuse the stored article text on reindex
+	content, fetched, err := s.loadContent(ctx, sourceUUID, job)
 	if err != nil {
-		_ = s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusFailed)
-		return err
+		return nil, err
 	}
 
-	// 2. Upload image to S3 if available
-	var imageS3URL string
-	if imgURL, ok := content.Metadata["image_url"].(string); ok && imgURL != "" {
-		keyPrefix := fmt.Sprintf("%s/%s", ImageKeyPrefix, job.UserID)
-		s3URL, err := s.s3.UploadFromURL(ctx, imgURL, keyPrefix)
-		if err != nil {
-			log.Printf("Warning: Failed to upload image to S3: %v", err)
-			// Continue without image, don't fail the whole process
-		} else {
-			imageS3URL = s3URL
-			log.Printf("Image uploaded to S3: %s", s3URL)
+	if fetched {
+		// 2. Upload image to S3 if available
+		var imageS3URL string
+		if imgURL, ok := content.Metadata["image_url"].(string); ok && imgURL != "" {
+			s3URL, err := s.s3.UploadFromURL(ctx, imgURL, ImageKey(job.UserID, job.SourceID))
+			if err != nil {
+				log.Printf("Warning: Failed to upload image to S3: %v", err)
+				// Continue without image, don't fail the whole process
+			} else {
+				imageS3URL = s3URL
+				log.Printf("Image uploaded to S3: %s", s3URL)
+			}
 		}
-	}
 
-	// 3. Update title and image in DB
-	if err := s.repo.UpdateTitleAndImage(ctx, sourceUUID, content.Title, imageS3URL); err != nil {
-		log.Printf("Warning: Failed to update title/image: %v", err)
-	}
+		// 3. Update title and image in DB
+		if err := s.repo.UpdateTitleAndImage(ctx, sourceUUID, content.Title, imageS3URL); err != nil {
+			log.Printf("Warning: Failed to update title/image: %v", err)
+		}
 
-	// 5. Chunking (1000 chars per chunk, 200 overlap)
-	chunks := utils.SplitText(content.Text, 1000, 200)
-	var vectors []pinecone.Vector
+		// Keep the article text for the reader view and later reindexes
+		if err := s.repo.SaveContent(ctx, sourceUUID, content.Text); err != nil {
+			log.Printf("Failed to save link content: %v", err)
+			return nil, err
+		}
+	}
 
-	// 6. Generate Embeddings & Prepare Vectors
-	for _, chunk := range chunks {
-		embedding, err := s.gemini.GenerateEmbedding(ctx, chunk.Text)
-		if err != nil {
-			log.Printf("Failed to embed chunk %d: %v", chunk.Index, err)
-			continue
+	// 4. Skip re-embedding if the text is the same as last time
+	hash := utils.ContentHash(content.Text)
+	if job.Unchanged(hash) {
+		log.Printf("Content of link %s is unchanged, skipping embedding", job.SourceID)
+		if err := s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusIndexed); err != nil {
+			return nil, err
 		}
+		return &modules.ProcessResult{Skipped: true}, nil
+	}
 
-		// Create Vector ID: sourceID_chunkIndex
-		vectorID := fmt.Sprintf("%s_%d", job.SourceID, chunk.Index)
-
-		vectors = append(vectors, pinecone.Vector{
-			ID:     vectorID,
-			Values: embedding,
-			Metadata: map[string]interface{}{
-				"source_id":   job.SourceID,
-				"text":        chunk.Text,
-				"url":         job.OriginalURL,
-				"title":       content.Title,
-				"chunk_index": chunk.Index,
-				"type":        "link",
-			},
-		})
+	// 5. Chunking
+	job.EnterStage(ctx, modules.StageChunk)
+	chunks, err := s.chunker.Chunk(ctx, job, content.Text)
+	if err != nil {
+		log.Printf("Failed to chunk link: %v", err)
+		return nil, err
 	}
 
-	// 7. Upsert to Pinecone with userID as namespace
-	if len(vectors) > 0 {
-		if _, err := s.pinecone.UpsertWithNamespace(ctx, job.UserID, vectors); err != nil {
-			log.Printf("Failed to upsert vectors: %v", err)
-			_ = s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusFailed)
-			return err
-		}
+	// 6. Embed & upsert to Pinecone with userID as namespace
+	count, err := s.indexer.Index(ctx, job, chunks, map[string]interface{}{
+		"url":   job.OriginalURL,
+		"title": content.Title,
+		"type":  "link",
+	})
+	if err != nil {
+		log.Printf("Failed to index link: %v", err)
+		return nil, err
+	}
+
+	if err := s.repo.UpdateContentHash(ctx, sourceUUID, hash); err != nil {
+		log.Printf("Warning: Failed to store content hash: %v", err)
 	}
 
-	// 8. Mark as Indexed
+	// 7. Mark as Indexed
 	if err := s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusIndexed); err != nil {
-		return err
+		return nil, err
 	}
 
 	log.Printf("Successfully processed and indexed link: %s", job.SourceID)
-	return nil
+	return &modules.ProcessResult{ChunkCount: count}, nil
+}
+
+// loadContent scrapes the link, or on reindex reads the article text stored by
+// an earlier run. Reports whether the page was actually fetched.
+func (s *Service) loadContent(ctx context.Context, sourceUUID pgtype.UUID, job modules.SourceJob) (*modules.ProcessedContent, bool, error) {
+	if job.ReuseContent {
+		text, err := s.repo.GetContent(ctx, sourceUUID, job.ContentVersion)
+		if err == nil {
+			return &modules.ProcessedContent{Title: job.Title, Text: text}, false, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, fmt.Errorf("failed to load stored content: %w", err)
+		}
+		if job.ContentVersion > 0 {
+			return nil, false, modules.Permanent(fmt.Errorf("content version %d not found", job.ContentVersion))
+		}
+		log.Printf("No stored content for link %s, scraping it again", job.SourceID)
+	}
+
+	content, err := s.processor.Process(ctx, job)
+	if err != nil {
+		return nil, false, err
+	}
+	return content, true, nil
 }
@@ -2,15 +2,18 @@ package notes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Repository interface {
-	GetContent(ctx context.Context, sourceID pgtype.UUID) (string, error)
+	GetContent(ctx context.Context, sourceID pgtype.UUID, version int) (string, error)
 	UpdateStatus(ctx context.Context, sourceID pgtype.UUID, status db.SourceStatus) error
+	UpdateContentHash(ctx context.Context, sourceID pgtype.UUID, hash string) error
 }
 
 type repository struct {
@@ -21,19 +24,26 @@ func NewRepository(q *db.Queries) Repository {
 	return &repository{q: q}
 }
 
-func (r *repository) GetContent(ctx context.Context, sourceID pgtype.UUID) (string, error) {
-	// 1. Fetch from source_contents table
-	// Note: The SQL generates 'GetSourceContentBySourceID' which returns a slice ([]db.SourceContent)
-	contents, err := r.q.GetSourceContentBySourceID(ctx, sourceID)
+func (r *repository) GetContent(ctx context.Context, sourceID pgtype.UUID, version int) (string, error) {
+	// 1. Fetch the requested version, or the current one when version is 0
+	var content db.SourceContent
+	var err error
+	if version > 0 {
+		content, err = r.q.GetSourceContentByVersion(ctx, db.GetSourceContentByVersionParams{
+			SourceID: sourceID,
+			Version:  int32(version),
+		})
+	} else {
+		content, err = r.q.GetLatestSourceContent(ctx, sourceID)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("no content found for source id: %v: %w", sourceID.String(), err)
+	}
 	if err != nil {
 		return "", err
 	}
-	if len(contents) == 0 {
-		return "", fmt.Errorf("no content found for source id: %v", sourceID)
-	}
 
-	// Return the text of the most recent entry (or the only one)
-	return contents[0].ContentText, nil
+	return content.ContentText, nil
 }
 
 func (r *repository) UpdateStatus(ctx context.Context, sourceID pgtype.UUID, status db.SourceStatus) error {
@@ -42,3 +52,10 @@ func (r *repository) UpdateStatus(ctx context.Context, sourceID pgtype.UUID, sta
 		Status: status,
 	})
 }
+
+func (r *repository) UpdateContentHash(ctx context.Context, sourceID pgtype.UUID, hash string) error {
+	return r.q.UpdateSourceContentHash(ctx, db.UpdateSourceContentHashParams{
+		ID:          sourceID,
+		ContentHash: pgtype.Text{String: hash, Valid: hash != ""},
+	})
+}
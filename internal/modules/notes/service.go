@@ -2,90 +2,91 @@ package notes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/Alkush-Pipania/source-service/internal/modules"
-	"github.com/Alkush-Pipania/source-service/pkg/client/gemini"
-	"github.com/Alkush-Pipania/source-service/pkg/client/pinecone"
+	"github.com/Alkush-Pipania/source-service/internal/modules/indexing"
 	"github.com/Alkush-Pipania/source-service/pkg/db"
 	"github.com/Alkush-Pipania/source-service/pkg/utils"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Service struct {
-	repo     Repository
-	gemini   *gemini.Client
-	pinecone *pinecone.Client
+	repo    Repository
+	chunker *indexing.Chunker
+	indexer *indexing.Indexer
 }
 
-func NewService(repo Repository, gem *gemini.Client, pine *pinecone.Client) *Service {
+func NewService(repo Repository, chunker *indexing.Chunker, indexer *indexing.Indexer) *Service {
 	return &Service{
-		repo:     repo,
-		gemini:   gem,
-		pinecone: pine,
+		repo:    repo,
+		chunker: chunker,
+		indexer: indexer,
 	}
 }
 
-func (s *Service) ProcessNote(ctx context.Context, job modules.SourceJob) error {
+func (s *Service) ProcessNote(ctx context.Context, job modules.SourceJob) (*modules.ProcessResult, error) {
 	log.Printf("Processing note: %s", job.SourceID)
 
 	var sourceUUID pgtype.UUID
 	if err := sourceUUID.Scan(job.SourceID); err != nil {
-		return fmt.Errorf("invalid source id: %w", err)
+		return nil, modules.Permanent(fmt.Errorf("invalid source id: %w", err))
 	}
 
 	// 1. Fetch Content from DB
 	// (API already saved it, we just need to read it to embed it)
-	text, err := s.repo.GetContent(ctx, sourceUUID)
+	job.EnterStage(ctx, modules.StageFetch)
+	text, err := s.repo.GetContent(ctx, sourceUUID, job.ContentVersion)
 	if err != nil {
 		log.Printf("Failed to get note content: %v", err)
-		_ = s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusFailed)
-		return err
+		if job.ContentVersion > 0 && errors.Is(err, pgx.ErrNoRows) {
+			// That version doesn't exist, retrying won't create it
+			err = modules.Permanent(err)
+		}
+		return nil, err
 	}
 
-	// 2. Chunking
-	// Notes might be short, but we still chunk to be safe and consistent
-	chunks := utils.SplitText(text, 1000, 200)
-	var vectors []pinecone.Vector
-
-	// 3. Generate Embeddings
-	for _, chunk := range chunks {
-		embedding, err := s.gemini.GenerateEmbedding(ctx, chunk.Text)
-		if err != nil {
-			log.Printf("Failed to embed note chunk %d: %v", chunk.Index, err)
-			continue
+	// 2. Skip re-embedding if the note hasn't changed since it was last indexed
+	hash := utils.ContentHash(text)
+	if job.Unchanged(hash) {
+		log.Printf("Note %s is unchanged, skipping embedding", job.SourceID)
+		if err := s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusIndexed); err != nil {
+			return nil, err
 		}
+		return &modules.ProcessResult{Skipped: true}, nil
+	}
 
-		vectorID := fmt.Sprintf("%s_%d", job.SourceID, chunk.Index)
+	// 3. Chunking
+	// Notes might be short, but we still chunk to be safe and consistent
+	job.EnterStage(ctx, modules.StageChunk)
+	chunks, err := s.chunker.Chunk(ctx, job, text)
+	if err != nil {
+		log.Printf("Failed to chunk note: %v", err)
+		return nil, err
+	}
 
-		vectors = append(vectors, pinecone.Vector{
-			ID:     vectorID,
-			Values: embedding,
-			Metadata: map[string]interface{}{
-				"source_id":   job.SourceID,
-				"text":        chunk.Text,
-				"title":       "Note", // Notes usually don't have titles in the content, maybe pass from job?
-				"chunk_index": chunk.Index,
-				"type":        "note",
-			},
-		})
+	// 4. Embed & upsert to Pinecone with userID as namespace
+	count, err := s.indexer.Index(ctx, job, chunks, map[string]interface{}{
+		"title": "Note", // Notes usually don't have titles in the content, maybe pass from job?
+		"type":  "note",
+	})
+	if err != nil {
+		log.Printf("Failed to index note: %v", err)
+		return nil, err
 	}
 
-	// 4. Upsert to Pinecone with userID as namespace
-	if len(vectors) > 0 {
-		if _, err := s.pinecone.UpsertWithNamespace(ctx, job.UserID, vectors); err != nil {
-			log.Printf("Failed to upsert note vectors: %v", err)
-			_ = s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusFailed)
-			return err
-		}
+	if err := s.repo.UpdateContentHash(ctx, sourceUUID, hash); err != nil {
+		log.Printf("Warning: Failed to store content hash: %v", err)
 	}
 
 	// 5. Mark as Indexed
 	if err := s.repo.UpdateStatus(ctx, sourceUUID, db.SourceStatusIndexed); err != nil {
-		return err
+		return nil, err
 	}
 
 	log.Printf("Successfully processed note: %s", job.SourceID)
-	return nil
+	return &modules.ProcessResult{ChunkCount: count}, nil
 }
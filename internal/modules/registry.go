@@ -0,0 +1,96 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrPermanent marks failures that retrying cannot fix
+var ErrPermanent = errors.New("permanent failure")
+
+// ErrCancelled is the cause of a job context cancelled by a cancel message
+var ErrCancelled = errors.New("job cancelled")
+
+// Permanent wraps err so that IsPermanent reports true for it
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// IsPermanent reports whether err, or anything it wraps, is a permanent failure
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// SourceHandler processes one kind of source end to end
+type SourceHandler interface {
+	Process(ctx context.Context, job SourceJob) (*ProcessResult, error)
+}
+
+// HandlerFunc adapts a plain function, e.g. a service method, to SourceHandler
+type HandlerFunc func(ctx context.Context, job SourceJob) (*ProcessResult, error)
+
+func (f HandlerFunc) Process(ctx context.Context, job SourceJob) (*ProcessResult, error) {
+	return f(ctx, job)
+}
+
+// WithTimeout bounds every Process call of handler to budget. A job that runs
+// out of time fails like any other and is retried. A budget <= 0 means no limit.
+func WithTimeout(handler SourceHandler, budget time.Duration) SourceHandler {
+	if budget <= 0 {
+		return handler
+	}
+
+	return HandlerFunc(func(ctx context.Context, job SourceJob) (*ProcessResult, error) {
+		ctx, cancel := context.WithTimeout(ctx, budget)
+		defer cancel()
+
+		result, err := handler.Process(ctx, job)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%s job exceeded its %s budget: %w", job.Type, budget, err)
+		}
+		return result, err
+	})
+}
+
+// Registry maps source type strings to their handlers.
+// Register everything at startup, before the consumer starts.
+type Registry struct {
+	handlers map[string]SourceHandler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]SourceHandler)}
+}
+
+// Register makes handler responsible for the given source types
+func (r *Registry) Register(handler SourceHandler, types ...string) {
+	for _, t := range types {
+		if _, exists := r.handlers[t]; exists {
+			panic(fmt.Sprintf("modules: handler for source type %q registered twice", t))
+		}
+		r.handlers[t] = handler
+	}
+}
+
+// Handler returns the handler for a source type. Unknown types are a
+// permanent failure, no amount of retrying will make them known.
+func (r *Registry) Handler(sourceType string) (SourceHandler, error) {
+	h, ok := r.handlers[sourceType]
+	if !ok {
+		return nil, Permanent(fmt.Errorf("unknown source type: %s", sourceType))
+	}
+	return h, nil
+}
+
+// Types lists every registered source type
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.handlers))
+	for t := range r.handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,61 @@
+package sources
+
+import (
+	"context"
+
+	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Repository defines the DB operations that apply to sources of any type
+type Repository interface {
+	// DeleteContent removes every extracted content row of the source
+	DeleteContent(ctx context.Context, sourceID pgtype.UUID) error
+
+	// DeleteChunkManifest forgets which chunks of the source are indexed
+	DeleteChunkManifest(ctx context.Context, sourceID pgtype.UUID) error
+
+	// DeleteParentChunks removes the stored parent sections of the source
+	DeleteParentChunks(ctx context.Context, sourceID pgtype.UUID) error
+
+	// ListContentVersions returns every stored content version of the source, newest first
+	ListContentVersions(ctx context.Context, sourceID pgtype.UUID) ([]db.ListSourceContentVersionsRow, error)
+
+	// ListByUser returns every source owned by the user, oldest first with ties broken by ID
+	ListByUser(ctx context.Context, userID pgtype.UUID) ([]db.Source, error)
+
+	// ListByCollection returns every source in the collection, in the same order as ListByUser
+	ListByCollection(ctx context.Context, collectionID pgtype.UUID) ([]db.Source, error)
+}
+
+type repository struct {
+	q *db.Queries
+}
+
+func NewRepository(q *db.Queries) Repository {
+	return &repository{q: q}
+}
+
+func (r *repository) DeleteContent(ctx context.Context, sourceID pgtype.UUID) error {
+	return r.q.DeleteSourceContentsBySourceID(ctx, sourceID)
+}
+
+func (r *repository) DeleteChunkManifest(ctx context.Context, sourceID pgtype.UUID) error {
+	return r.q.DeleteSourceChunkManifestsBySourceID(ctx, sourceID)
+}
+
+func (r *repository) DeleteParentChunks(ctx context.Context, sourceID pgtype.UUID) error {
+	return r.q.DeleteSourceParentChunksBySourceID(ctx, sourceID)
+}
+
+func (r *repository) ListContentVersions(ctx context.Context, sourceID pgtype.UUID) ([]db.ListSourceContentVersionsRow, error) {
+	return r.q.ListSourceContentVersions(ctx, sourceID)
+}
+
+func (r *repository) ListByUser(ctx context.Context, userID pgtype.UUID) ([]db.Source, error) {
+	return r.q.ListSourcesByUser(ctx, userID)
+}
+
+func (r *repository) ListByCollection(ctx context.Context, collectionID pgtype.UUID) ([]db.Source, error) {
+	return r.q.ListSourcesByCollection(ctx, collectionID)
+}
@@ -0,0 +1,190 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/Alkush-Pipania/source-service/internal/modules"
+	"github.com/Alkush-Pipania/source-service/internal/modules/links"
+	"github.com/Alkush-Pipania/source-service/pkg/client/pinecone"
+	"github.com/Alkush-Pipania/source-service/pkg/client/s3"
+	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/Alkush-Pipania/source-service/pkg/rabbitmq"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Service handles operations that are the same for every source type
+type Service struct {
+	repo       Repository
+	pinecone   *pinecone.Client
+	s3         *s3.Client
+	publisher  *rabbitmq.Publisher
+	routingKey string
+}
+
+// NewService creates a new sources service. Bulk operations fan out one
+// message per source, published with routingKey back onto the processing queue.
+func NewService(repo Repository, pine *pinecone.Client, s3Client *s3.Client, publisher *rabbitmq.Publisher, routingKey string) *Service {
+	return &Service{
+		repo:       repo,
+		pinecone:   pine,
+		s3:         s3Client,
+		publisher:  publisher,
+		routingKey: routingKey,
+	}
+}
+
+// DeleteVectors removes every chunk vector of the source from the user's
+// namespace, along with the chunk manifest that described them and the
+// parent sections they pointed at
+func (s *Service) DeleteVectors(ctx context.Context, job modules.SourceJob) (int, error) {
+	var sourceUUID pgtype.UUID
+	if err := sourceUUID.Scan(job.SourceID); err != nil {
+		return 0, modules.Permanent(fmt.Errorf("invalid source id: %w", err))
+	}
+
+	deleted, err := s.pinecone.DeleteByPrefix(ctx, job.UserID, modules.VectorPrefix(job.SourceID))
+	if err != nil {
+		return deleted, err
+	}
+
+	if err := s.repo.DeleteChunkManifest(ctx, sourceUUID); err != nil {
+		return deleted, fmt.Errorf("failed to delete chunk manifest: %w", err)
+	}
+	if err := s.repo.DeleteParentChunks(ctx, sourceUUID); err != nil {
+		return deleted, fmt.Errorf("failed to delete parent chunks: %w", err)
+	}
+	return deleted, nil
+}
+
+// ContentVersions lists the stored content versions of a source, newest first.
+// Any of them can be re-embedded with an ActionReindex message carrying its content_version.
+func (s *Service) ContentVersions(ctx context.Context, sourceID string) ([]db.ListSourceContentVersionsRow, error) {
+	var sourceUUID pgtype.UUID
+	if err := sourceUUID.Scan(sourceID); err != nil {
+		return nil, modules.Permanent(fmt.Errorf("invalid source id: %w", err))
+	}
+	return s.repo.ListContentVersions(ctx, sourceUUID)
+}
+
+// ReindexUser enqueues a reindex for every source the user owns, or only for
+// those after the cursor when after is set. Returns how many were enqueued and
+// the cursor of the last one, nil if none were.
+func (s *Service) ReindexUser(ctx context.Context, userID string, after *modules.ReindexCursor) (int, *modules.ReindexCursor, error) {
+	var userUUID pgtype.UUID
+	if err := userUUID.Scan(userID); err != nil {
+		return 0, nil, modules.Permanent(fmt.Errorf("invalid user id: %w", err))
+	}
+
+	sources, err := s.repo.ListByUser(ctx, userUUID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to list sources for user: %w", err)
+	}
+
+	return s.enqueueReindex(ctx, sources, after)
+}
+
+// ReindexCollection enqueues a reindex for every source in the collection, see ReindexUser
+func (s *Service) ReindexCollection(ctx context.Context, collectionID string, after *modules.ReindexCursor) (int, *modules.ReindexCursor, error) {
+	var collectionUUID pgtype.UUID
+	if err := collectionUUID.Scan(collectionID); err != nil {
+		return 0, nil, modules.Permanent(fmt.Errorf("invalid collection id: %w", err))
+	}
+
+	sources, err := s.repo.ListByCollection(ctx, collectionUUID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to list sources for collection: %w", err)
+	}
+
+	return s.enqueueReindex(ctx, sources, after)
+}
+
+// ResumeReindex publishes the bulk reindex message again with its cursor moved
+// to after, so a retry picks up where an interrupted fan-out stopped instead of
+// enqueueing every source a second time
+func (s *Service) ResumeReindex(ctx context.Context, message modules.SourceProcessingMessage, after modules.ReindexCursor) error {
+	message.ReindexAfter = &after
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if err := s.publisher.Publish(ctx, s.routingKey, body); err != nil {
+		return fmt.Errorf("failed to enqueue the rest of the %s: %w", message.Action, err)
+	}
+	return nil
+}
+
+// enqueueReindex publishes one reindex message per source after the cursor so
+// each one gets its own retries instead of failing the whole batch
+func (s *Service) enqueueReindex(ctx context.Context, sources []db.Source, after *modules.ReindexCursor) (int, *modules.ReindexCursor, error) {
+	var last *modules.ReindexCursor
+	count := 0
+	for _, source := range sources {
+		cursor := modules.ReindexCursor{CreatedAt: source.CreatedAt.Time, SourceID: source.ID.String()}
+		if after != nil && !after.Before(cursor) {
+			continue
+		}
+
+		body, err := json.Marshal(modules.SourceProcessingMessage{
+			SourceID: source.ID.String(),
+			Type:     string(source.Type),
+			UserID:   source.UserID.String(),
+			Action:   modules.ActionReindex,
+		})
+		if err != nil {
+			return count, last, err
+		}
+
+		if err := s.publisher.Publish(ctx, s.routingKey, body); err != nil {
+			return count, last, fmt.Errorf("failed to enqueue reindex for %s: %w", source.ID.String(), err)
+		}
+		last = &cursor
+		count++
+	}
+
+	log.Printf("Enqueued reindex for %d sources", count)
+	return count, last, nil
+}
+
+// DeleteSource purges everything the service stored for a source: its vectors,
+// its extracted content and any link images uploaded for it.
+// Every step tolerates missing data, so the operation can safely be repeated.
+func (s *Service) DeleteSource(ctx context.Context, job modules.SourceJob) error {
+	log.Printf("Deleting source: %s", job.SourceID)
+
+	var sourceUUID pgtype.UUID
+	if err := sourceUUID.Scan(job.SourceID); err != nil {
+		return modules.Permanent(fmt.Errorf("invalid source id: %w", err))
+	}
+
+	// 1. Remove all chunk vectors from the user's namespace
+	deleted, err := s.DeleteVectors(ctx, job)
+	if err != nil {
+		return err
+	}
+	log.Printf("Deleted %d vectors for source %s", deleted, job.SourceID)
+
+	// 2. Remove extracted content
+	if err := s.repo.DeleteContent(ctx, sourceUUID); err != nil {
+		return fmt.Errorf("failed to delete source content: %w", err)
+	}
+
+	// 3. Remove uploaded link images
+	if _, err := s.s3.DeletePrefix(ctx, links.ImagePrefix(job.UserID, job.SourceID)+"/"); err != nil {
+		return err
+	}
+
+	// Images uploaded before keys were scoped per source can only be found via the stored URL
+	if job.ImageURL != "" {
+		if key, ok := s.s3.KeyFromURL(job.ImageURL); ok {
+			if err := s.s3.DeleteObject(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Printf("Successfully deleted source: %s", job.SourceID)
+	return nil
+}
@@ -1,10 +1,50 @@
 package modules
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Message actions, an empty action means ActionProcess
+const (
+	ActionProcess           = "process"
+	ActionDelete            = "delete"
+	ActionReindex           = "reindex"
+	ActionReindexUser       = "reindex_user"
+	ActionReindexCollection = "reindex_collection"
+	ActionCancel            = "cancel"
+)
+
 // SourceProcessingMessage is the message received from the queue
 type SourceProcessingMessage struct {
 	SourceID string `json:"source_id"`
 	Type     string `json:"type"` // "link", "note", "pdf", "ppt", "doc"
 	UserID   string `json:"user_id"`
+	Action   string `json:"action,omitempty"` // "process" (default), "delete", "reindex", "reindex_user", "reindex_collection", "cancel"
+
+	// CollectionID selects the sources for ActionReindexCollection
+	CollectionID string `json:"collection_id,omitempty"`
+	// ContentVersion makes ActionReindex use a historical version of the stored content
+	ContentVersion int `json:"content_version,omitempty"`
+	// ReindexAfter resumes a bulk reindex after the last source it enqueued
+	ReindexAfter *ReindexCursor `json:"reindex_after,omitempty"`
+}
+
+// ReindexCursor marks a source in the order bulk reindexes enqueue them, by
+// creation time and then ID
+type ReindexCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	SourceID  string    `json:"source_id"`
+}
+
+// Before reports whether c comes before other
+func (c ReindexCursor) Before(other ReindexCursor) bool {
+	if !c.CreatedAt.Equal(other.CreatedAt) {
+		return c.CreatedAt.Before(other.CreatedAt)
+	}
+	return c.SourceID < other.SourceID
 }
 
 // SourceJob is the enriched job with full details from DB
@@ -16,6 +56,104 @@ type SourceJob struct {
 	S3Bucket    string
 	S3Key       string
 	Title       string
+	ImageURL    string
+
+	// ContentHash is the hash of the content last indexed successfully
+	ContentHash string
+	// Force re-embeds the content even if it is unchanged
+	Force bool
+	// ReuseContent reads the stored text instead of scraping or parsing the source again
+	ReuseContent bool
+	// ContentVersion selects which stored version to read, 0 means the current one
+	ContentVersion int
+
+	// Tracker records progress for this attempt, nil when untracked
+	Tracker Tracker
+}
+
+// Unchanged reports whether content with the given hash was already indexed
+// and the job may skip embedding it again
+func (j SourceJob) Unchanged(hash string) bool {
+	return !j.Force && j.ContentHash != "" && j.ContentHash == hash
+}
+
+// EnterStage records that the job moved on to stage
+func (j SourceJob) EnterStage(ctx context.Context, stage Stage) {
+	if j.Tracker != nil {
+		j.Tracker.EnterStage(ctx, stage)
+	}
+}
+
+// RecordCounts records how many chunks were produced, how many vectors were
+// written and how many duplicate chunks were dropped
+func (j SourceJob) RecordCounts(ctx context.Context, chunks, vectors, duplicates int) {
+	if j.Tracker != nil {
+		j.Tracker.RecordCounts(ctx, chunks, vectors, duplicates)
+	}
+}
+
+// Stage is a step of the processing pipeline
+type Stage string
+
+const (
+	StageFetch  Stage = "fetch"
+	StageParse  Stage = "parse"
+	StageChunk  Stage = "chunk"
+	StageEmbed  Stage = "embed"
+	StageUpsert Stage = "upsert"
+)
+
+// Tracker records a job's progress through the pipeline stages.
+// Implementations must not fail the job when recording fails.
+type Tracker interface {
+	EnterStage(ctx context.Context, stage Stage)
+	RecordCounts(ctx context.Context, chunks, vectors, duplicates int)
+}
+
+// VectorID is the Pinecone ID of a source's chunk: sourceID_chunkIndex
+func VectorID(sourceID string, chunkIndex int) string {
+	return fmt.Sprintf("%s_%d", sourceID, chunkIndex)
+}
+
+// ParentID identifies a source's parent section, stored in source_parent_chunks
+// under (source_id, parent_index): sourceID_pParentIndex
+func ParentID(sourceID string, parentIndex int) string {
+	return fmt.Sprintf("%s_p%d", sourceID, parentIndex)
+}
+
+// VectorPrefix matches every chunk vector of a source
+func VectorPrefix(sourceID string) string {
+	return sourceID + "_"
+}
+
+// ProcessResult summarizes a successfully processed source
+type ProcessResult struct {
+	ChunkCount int
+	// Skipped is set when the content was unchanged and nothing was re-embedded
+	Skipped bool
+}
+
+// Source lifecycle events, each published with its own routing key
+const (
+	EventSourceProcessing = "source.processing"
+	EventSourceIndexed    = "source.indexed"
+	EventSourceRetrying   = "source.retrying" // failed, another attempt follows
+	EventSourceFailed     = "source.failed"   // failed for good
+	EventSourceCancelled  = "source.cancelled"
+)
+
+// SourceEvent is published to the exchange as a source moves through processing
+type SourceEvent struct {
+	Event      string    `json:"event"`
+	SourceID   string    `json:"source_id"`
+	UserID     string    `json:"user_id"`
+	Type       string    `json:"type"`
+	Attempt    int       `json:"attempt"`
+	ChunkCount int       `json:"chunk_count"`
+	Skipped    bool      `json:"skipped,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
 }
 
 // ProcessedContent holds the result of processing a source
@@ -23,4 +161,9 @@ type ProcessedContent struct {
 	Title    string
 	Text     string
 	Metadata map[string]interface{}
+	// PageStarts is the character offset in Text (or Body) at which each page (or slide) starts, nil for unpaged content
+	PageStarts []int
+	// Body replaces Text for content too large to hold in memory. It is read
+	// more than once, so it must be seekable, and the receiver must close it.
+	Body io.ReadSeekCloser
 }
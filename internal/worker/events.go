@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Alkush-Pipania/source-service/internal/modules"
+)
+
+const eventPublishTimeout = 5 * time.Second
+
+// publishEvent sends a lifecycle event for the job. Failures are logged but
+// never fail the job itself, the status column stays the source of truth.
+func (w *Worker) publishEvent(event string, job modules.SourceJob, attempt int, started time.Time, result *modules.ProcessResult, jobErr error) {
+	if w.events == nil {
+		return
+	}
+
+	e := modules.SourceEvent{
+		Event:      event,
+		SourceID:   job.SourceID,
+		UserID:     job.UserID,
+		Type:       job.Type,
+		Attempt:    attempt,
+		DurationMs: time.Since(started).Milliseconds(),
+		OccurredAt: time.Now().UTC(),
+	}
+	if result != nil {
+		e.ChunkCount = result.ChunkCount
+		e.Skipped = result.Skipped
+	}
+	if jobErr != nil {
+		e.Error = jobErr.Error()
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", event, err)
+		return
+	}
+
+	// Detached from the job context so events still go out while shutting down
+	ctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+	defer cancel()
+
+	if err := w.events.Publish(ctx, event, body); err != nil {
+		log.Printf("Failed to publish %s event for %s: %v", event, job.SourceID, err)
+	}
+}
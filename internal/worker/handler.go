@@ -4,51 +4,120 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/Alkush-Pipania/source-service/internal/app"
 	"github.com/Alkush-Pipania/source-service/internal/modules"
+	"github.com/Alkush-Pipania/source-service/internal/modules/jobs"
 	"github.com/Alkush-Pipania/source-service/pkg/db"
+	"github.com/Alkush-Pipania/source-service/pkg/rabbitmq"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/rabbitmq/amqp091-go"
 )
 
+// cancelPollInterval is how often a running job checks for a cancel
+// requested through another worker
+const cancelPollInterval = 2 * time.Second
+
 type Worker struct {
 	services *app.Services
+	registry *modules.Registry
 	db       *db.Queries
+	events   *rabbitmq.Publisher
+
+	// maxRetries matches the consumer's, an attempt past it is the last one
+	maxRetries int
+
+	mu      sync.Mutex
+	running map[string]runningJob // in-flight jobs by source ID
 }
 
-func NewWorker(services *app.Services, queries *db.Queries) *Worker {
+type runningJob struct {
+	published time.Time
+	cancel    context.CancelCauseFunc
+}
+
+func NewWorker(services *app.Services, registry *modules.Registry, queries *db.Queries, events *rabbitmq.Publisher, maxRetries int) *Worker {
 	return &Worker{
-		services: services,
-		db:       queries,
+		services:   services,
+		registry:   registry,
+		db:         queries,
+		events:     events,
+		maxRetries: maxRetries,
+		running:    make(map[string]runningJob),
 	}
 }
 
-func (w *Worker) HandleMessage(msg amqp091.Delivery) {
+// MessageKey returns the source ID of a delivery so the consumer never runs
+// two jobs for the same source at once. Cancel messages are handled inline,
+// they must not queue up behind the very job they are meant to stop. They
+// normally arrive on the control queue, see rabbitmq.ConsumerConfig.
+func (w *Worker) MessageKey(msg amqp091.Delivery) string {
+	var message modules.SourceProcessingMessage
+	if err := json.Unmarshal(msg.Body, &message); err != nil {
+		return ""
+	}
+	if message.Action == modules.ActionCancel {
+		return rabbitmq.KeyInline
+	}
+	return message.SourceID
+}
+
+// HandleMessage processes a single delivery. Malformed messages and permanent
+// failures are rejected outright, other failures are handed back to the
+// consumer for retry. Jobs interrupted by shutdown (ctx cancelled) are requeued.
+func (w *Worker) HandleMessage(ctx context.Context, msg amqp091.Delivery) rabbitmq.Result {
 	log.Printf("Received message: %s", msg.Body)
 
-	ctx := context.Background()
+	started := time.Now()
+	attempt := rabbitmq.RetryCount(msg) + 1
+
+	// Cancels apply to jobs published before them. Retries keep the original
+	// timestamp; without one, only a job that is already running can be cancelled.
+	published := msg.Timestamp
+	if published.IsZero() {
+		published = started
+	}
 
 	// Parse message from queue
 	var message modules.SourceProcessingMessage
 	if err := json.Unmarshal(msg.Body, &message); err != nil {
 		log.Printf("Failed to parse message: %v", err)
-		return
+		return rabbitmq.Reject
+	}
+
+	// Bulk reindex fans out one message per source and carries no source ID
+	switch message.Action {
+	case modules.ActionReindexUser, modules.ActionReindexCollection:
+		return w.handleBulkReindex(ctx, message)
+	case modules.ActionCancel:
+		return w.handleCancel(ctx, msg, message)
 	}
 
 	// Convert source ID to UUID
 	var sourceUUID pgtype.UUID
 	if err := sourceUUID.Scan(message.SourceID); err != nil {
 		log.Printf("Invalid source ID: %v", err)
-		return
+		return rabbitmq.Reject
+	}
+
+	if message.Action == modules.ActionDelete {
+		return w.handleDelete(ctx, message, sourceUUID)
 	}
 
 	// Fetch full source details from DB
 	source, err := w.db.GetSourceByID(ctx, sourceUUID)
 	if err != nil {
 		log.Printf("Failed to get source from DB: %v", err)
-		return
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Source was deleted, retrying won't bring it back
+			err = modules.Permanent(err)
+		}
+		return resultFor(ctx, err)
 	}
 
 	// Build enriched job
@@ -60,26 +129,315 @@ func (w *Worker) HandleMessage(msg amqp091.Delivery) {
 		S3Bucket:    source.S3Bucket.String,
 		S3Key:       source.S3Key.String,
 		Title:       source.Title,
+		ContentHash: source.ContentHash.String,
+		// Reindex wipes the vectors first, so it must never be skipped, and
+		// re-embeds the text already stored instead of fetching it again
+		Force:        message.Action == modules.ActionReindex,
+		ReuseContent: message.Action == modules.ActionReindex,
+	}
+	if message.Action == modules.ActionReindex {
+		job.ContentVersion = message.ContentVersion
 	}
 
-	// Process based on job type
-	switch job.Type {
-	case "link":
-		err = w.services.Links.ProcessLink(ctx, job)
-	case "note":
-		err = w.services.Notes.ProcessNote(ctx, job)
-	case "pdf", "ppt", "doc":
-		// All document types go through docs processor
-		err = w.services.Docs.ProcessDoc(ctx, job)
-	default:
-		log.Printf("Unknown job type: %s", job.Type)
-		return
+	// Cancelled while it was queued, or parked behind another job for the source
+	if w.cancelRequested(ctx, sourceUUID, published) {
+		return w.finishCancelled(ctx, sourceUUID, job, nil, attempt, started)
+	}
+
+	// Resolve the handler for this source type
+	handler, err := w.registry.Handler(job.Type)
+	if err != nil {
+		log.Println(err)
+		return w.fail(ctx, sourceUUID, job, nil, attempt, started, err)
+	}
+
+	if err := w.db.UpdateSourceStatus(ctx, db.UpdateSourceStatusParams{
+		ID:     sourceUUID,
+		Status: db.SourceStatusProcessing,
+	}); err != nil {
+		log.Printf("Warning: Failed to mark source as processing: %v", err)
+	}
+	w.publishEvent(modules.EventSourceProcessing, job, attempt, started, nil, nil)
+
+	// Record this attempt so failures can be explained from the database
+	action := message.Action
+	if action == "" {
+		action = modules.ActionProcess
+	}
+	run, err := w.services.Jobs.Start(ctx, sourceUUID, action, attempt)
+	if err != nil {
+		log.Printf("Warning: Failed to record processing job for %s: %v", job.SourceID, err)
+	} else {
+		job.Tracker = run
 	}
 
+	// A cancel message for this source aborts the job through jobCtx
+	jobCtx, release := w.track(ctx, sourceUUID, job.SourceID, published)
+	defer release()
+
+	// Reindex starts from a clean slate so a shorter result leaves no stale chunks behind
+	if message.Action == modules.ActionReindex {
+		deleted, err := w.services.Sources.DeleteVectors(jobCtx, job)
+		if err != nil && cancelled(jobCtx) {
+			return w.finishCancelled(ctx, sourceUUID, job, run, attempt, started)
+		}
+		if err != nil {
+			log.Printf("Failed to clear vectors before reindex of %s: %v", job.SourceID, err)
+			return w.fail(ctx, sourceUUID, job, run, attempt, started, err)
+		}
+		log.Printf("Cleared %d vectors before reindex of %s", deleted, job.SourceID)
+	}
+
+	result, err := handler.Process(jobCtx, job)
+	if err != nil && ctx.Err() != nil {
+		log.Printf("Interrupted %s job %s, requeueing: %v", job.Type, job.SourceID, err)
+		if run != nil {
+			run.Fail(ctx, err)
+		}
+		return rabbitmq.Requeue
+	}
+	if err != nil && cancelled(jobCtx) {
+		return w.finishCancelled(ctx, sourceUUID, job, run, attempt, started)
+	}
 	if err != nil {
-		log.Printf("Failed to process %s job: %v", job.Type, err)
-		return
+		log.Printf("Failed to process %s job (attempt %d): %v", job.Type, attempt, err)
+		return w.fail(ctx, sourceUUID, job, run, attempt, started, err)
+	}
+
+	// The version that was just indexed is now the one readers should see
+	if job.ContentVersion > 0 {
+		if err := w.db.SetCurrentSourceContentVersion(ctx, db.SetCurrentSourceContentVersionParams{
+			SourceID: sourceUUID,
+			Version:  int32(job.ContentVersion),
+		}); err != nil {
+			log.Printf("Warning: Failed to mark content version %d as current: %v", job.ContentVersion, err)
+		}
+	}
+
+	if run != nil && result.Skipped {
+		run.Skip(ctx)
+	} else if run != nil {
+		run.Succeed(ctx)
 	}
 
 	log.Printf("Successfully processed %s job: %s", job.Type, job.SourceID)
+	w.publishEvent(modules.EventSourceIndexed, job, attempt, started, result, nil)
+	return rabbitmq.Ack
+}
+
+// track registers a cancellable context for the source's job and watches for
+// cancels recorded by other workers. The returned release func must be called
+// once the job is done.
+func (w *Worker) track(ctx context.Context, sourceUUID pgtype.UUID, sourceID string, published time.Time) (context.Context, func()) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+
+	w.mu.Lock()
+	w.running[sourceID] = runningJob{published: published, cancel: cancel}
+	w.mu.Unlock()
+
+	go w.watchCancel(jobCtx, sourceUUID, published, cancel)
+
+	return jobCtx, func() {
+		w.mu.Lock()
+		delete(w.running, sourceID)
+		w.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// watchCancel polls for a cancel of the job until it ends
+func (w *Worker) watchCancel(jobCtx context.Context, sourceUUID pgtype.UUID, published time.Time, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-jobCtx.Done():
+			return
+		case <-ticker.C:
+			if w.cancelRequested(jobCtx, sourceUUID, published) {
+				cancel(modules.ErrCancelled)
+				return
+			}
+		}
+	}
+}
+
+// cancelRequested reports whether the source's job published at published was
+// cancelled. Lookup failures are logged and the job carries on.
+func (w *Worker) cancelRequested(ctx context.Context, sourceUUID pgtype.UUID, published time.Time) bool {
+	requested, err := w.services.Jobs.CancelRequested(ctx, sourceUUID, published)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Warning: Failed to check for cancel requests: %v", err)
+		}
+		return false
+	}
+	return requested
+}
+
+// handleCancel records the cancel in processing_jobs, where running jobs poll
+// for it and queued ones check it before they start, so it reaches the job on
+// whichever worker it is. A job running in this worker is stopped right away.
+func (w *Worker) handleCancel(ctx context.Context, msg amqp091.Delivery, message modules.SourceProcessingMessage) rabbitmq.Result {
+	var sourceUUID pgtype.UUID
+	if err := sourceUUID.Scan(message.SourceID); err != nil {
+		log.Printf("Invalid source ID: %v", err)
+		return rabbitmq.Reject
+	}
+
+	requested := msg.Timestamp
+	if requested.IsZero() {
+		requested = time.Now()
+	}
+	if err := w.services.Jobs.RequestCancel(ctx, sourceUUID, requested); err != nil {
+		log.Printf("Failed to cancel jobs for %s: %v", message.SourceID, err)
+		return resultFor(ctx, err)
+	}
+
+	w.mu.Lock()
+	job, ok := w.running[message.SourceID]
+	w.mu.Unlock()
+
+	if ok && requested.After(job.published) {
+		log.Printf("Cancelling in-flight job for %s", message.SourceID)
+		job.cancel(modules.ErrCancelled)
+	} else {
+		log.Printf("Recorded cancel for %s, no job for it runs in this worker", message.SourceID)
+	}
+	return rabbitmq.Ack
+}
+
+// finishCancelled records a job stopped by a cancel message. The message is
+// acked, a cancelled job is neither failed nor retried.
+func (w *Worker) finishCancelled(ctx context.Context, sourceUUID pgtype.UUID, job modules.SourceJob, run *jobs.Run, attempt int, started time.Time) rabbitmq.Result {
+	log.Printf("Cancelled %s job %s", job.Type, job.SourceID)
+
+	if run != nil {
+		run.Cancel(ctx, modules.ErrCancelled)
+	}
+	if err := w.db.UpdateSourceStatus(ctx, db.UpdateSourceStatusParams{
+		ID:     sourceUUID,
+		Status: db.SourceStatusCancelled,
+	}); err != nil {
+		log.Printf("Warning: Failed to mark source as cancelled: %v", err)
+	}
+	w.publishEvent(modules.EventSourceCancelled, job, attempt, started, nil, nil)
+	return rabbitmq.Ack
+}
+
+// cancelled reports whether jobCtx was stopped by a cancel message
+func cancelled(jobCtx context.Context) bool {
+	return errors.Is(context.Cause(jobCtx), modules.ErrCancelled)
+}
+
+// handleDelete purges a source's vectors, content and S3 artifacts. The source
+// row may already be gone, so everything needed comes from the message.
+func (w *Worker) handleDelete(ctx context.Context, message modules.SourceProcessingMessage, sourceUUID pgtype.UUID) rabbitmq.Result {
+	if message.UserID == "" {
+		log.Printf("Delete message for %s has no user ID", message.SourceID)
+		return rabbitmq.Reject
+	}
+
+	job := modules.SourceJob{
+		SourceID: message.SourceID,
+		Type:     message.Type,
+		UserID:   message.UserID,
+	}
+
+	// Still there? Then it may point at an image stored under a legacy key.
+	source, err := w.db.GetSourceByID(ctx, sourceUUID)
+	if err == nil {
+		job.ImageURL = source.ImageUrl.String
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("Warning: Failed to look up source %s before delete: %v", message.SourceID, err)
+	}
+
+	if err := w.services.Sources.DeleteSource(ctx, job); err != nil {
+		log.Printf("Failed to delete source %s: %v", message.SourceID, err)
+		return resultFor(ctx, err)
+	}
+
+	return rabbitmq.Ack
+}
+
+// handleBulkReindex enqueues a reindex message for every source of a user or
+// collection. A fan-out that fails partway is continued by a new message that
+// starts after the last source enqueued, a retry of this one would enqueue
+// them all again.
+func (w *Worker) handleBulkReindex(ctx context.Context, message modules.SourceProcessingMessage) rabbitmq.Result {
+	var (
+		count int
+		last  *modules.ReindexCursor
+		err   error
+	)
+
+	switch message.Action {
+	case modules.ActionReindexUser:
+		count, last, err = w.services.Sources.ReindexUser(ctx, message.UserID, message.ReindexAfter)
+	case modules.ActionReindexCollection:
+		count, last, err = w.services.Sources.ReindexCollection(ctx, message.CollectionID, message.ReindexAfter)
+	}
+
+	if err != nil {
+		log.Printf("Failed bulk %s after enqueueing %d sources: %v", message.Action, count, err)
+		if last == nil || ctx.Err() != nil {
+			return resultFor(ctx, err)
+		}
+		if err := w.services.Sources.ResumeReindex(ctx, message, *last); err != nil {
+			log.Printf("Failed to continue bulk %s: %v", message.Action, err)
+			return resultFor(ctx, err)
+		}
+		log.Printf("Bulk %s continues after source %s", message.Action, last.SourceID)
+		return rabbitmq.Ack
+	}
+
+	log.Printf("Bulk %s enqueued %d sources", message.Action, count)
+	return rabbitmq.Ack
+}
+
+// fail records a failed attempt and settles its message. The source is only
+// marked failed, and source.failed only published, once the failure is final:
+// the error is permanent or the message has no retries left. An attempt that
+// will be retried publishes source.retrying instead.
+func (w *Worker) fail(ctx context.Context, sourceUUID pgtype.UUID, job modules.SourceJob, run *jobs.Run, attempt int, started time.Time, err error) rabbitmq.Result {
+	if run != nil {
+		run.Fail(ctx, err)
+	}
+
+	result := resultFor(ctx, err)
+	switch {
+	case result == rabbitmq.Requeue:
+		// Interrupted by shutdown, the attempt runs again elsewhere
+	case result == rabbitmq.Reject || attempt > w.maxRetries:
+		w.markFailed(ctx, sourceUUID)
+		w.publishEvent(modules.EventSourceFailed, job, attempt, started, nil, err)
+	default:
+		w.publishEvent(modules.EventSourceRetrying, job, attempt, started, nil, err)
+	}
+	return result
+}
+
+// markFailed flags the source as failed, best effort
+func (w *Worker) markFailed(ctx context.Context, sourceUUID pgtype.UUID) {
+	if err := w.db.UpdateSourceStatus(ctx, db.UpdateSourceStatusParams{
+		ID:     sourceUUID,
+		Status: db.SourceStatusFailed,
+	}); err != nil {
+		log.Printf("Warning: Failed to mark source as failed: %v", err)
+	}
+}
+
+// resultFor maps a job error to how its message should be settled
+func resultFor(ctx context.Context, err error) rabbitmq.Result {
+	switch {
+	case err == nil:
+		return rabbitmq.Ack
+	case ctx.Err() != nil:
+		return rabbitmq.Requeue
+	case modules.IsPermanent(err):
+		return rabbitmq.Reject
+	default:
+		return rabbitmq.Retry
+	}
 }
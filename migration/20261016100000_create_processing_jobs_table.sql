@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+------------------------------------------------
+-- PROCESSING JOBS (one row per processing attempt)
+------------------------------------------------
+CREATE TYPE processing_job_status AS ENUM (
+    'running',
+    'succeeded',
+    'failed'
+);
+
+CREATE TABLE IF NOT EXISTS processing_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_id UUID NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+
+    action TEXT NOT NULL DEFAULT 'process',
+    attempt INT NOT NULL DEFAULT 1,
+    status processing_job_status NOT NULL DEFAULT 'running',
+
+    -- Current stage: fetch, parse, chunk, embed, upsert
+    stage TEXT,
+    -- When each stage started, e.g. {"fetch": "...", "chunk": "..."}
+    stage_started_at JSONB NOT NULL DEFAULT '{}'::jsonb,
+
+    chunk_count INT NOT NULL DEFAULT 0,
+    vector_count INT NOT NULL DEFAULT 0,
+
+    -- Structured failure, e.g. {"stage": "parse", "message": "...", "permanent": false}
+    last_error JSONB,
+
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_source_id ON processing_jobs(source_id, started_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_processing_jobs_source_id;
+DROP TABLE IF EXISTS processing_jobs;
+DROP TYPE IF EXISTS processing_job_status;
+-- +goose StatementEnd
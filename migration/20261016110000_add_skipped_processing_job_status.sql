@@ -0,0 +1,8 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Jobs whose content hash matched the last indexed version and did no embedding work
+ALTER TYPE processing_job_status ADD VALUE IF NOT EXISTS 'skipped';
+
+-- +goose Down
+-- Postgres cannot drop an enum value, fold skipped jobs into succeeded instead
+UPDATE processing_jobs SET status = 'succeeded' WHERE status = 'skipped';
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+------------------------------------------------
+-- CHUNK MANIFEST (one row per indexed chunk vector)
+------------------------------------------------
+CREATE TABLE IF NOT EXISTS source_chunk_manifests (
+    source_id UUID NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    chunk_index INT NOT NULL,
+
+    -- Hash of the chunk text behind the {source_id}_{chunk_index} vector
+    text_hash TEXT NOT NULL,
+    -- Hash of the metadata stored on the vector (offsets, pages, title...), so
+    -- a chunk whose text didn't change gets only its metadata rewritten
+    meta_hash TEXT NOT NULL DEFAULT '',
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (source_id, chunk_index)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS source_chunk_manifests;
+-- +goose StatementEnd
@@ -0,0 +1,72 @@
+-- +goose Up
+-- +goose StatementBegin
+------------------------------------------------
+-- SOURCE CONTENT VERSIONS
+------------------------------------------------
+ALTER TABLE source_contents
+    ADD COLUMN version INT,
+    ADD COLUMN byte_length INT,
+    ADD COLUMN is_current BOOLEAN NOT NULL DEFAULT true;
+
+-- Number existing rows in insertion order, the newest one is current
+UPDATE source_contents sc
+SET version = v.version,
+    byte_length = octet_length(sc.content_text),
+    is_current = v.version = v.total
+FROM (
+    SELECT id,
+           ROW_NUMBER() OVER (PARTITION BY source_id ORDER BY created_at, id) AS version,
+           COUNT(*) OVER (PARTITION BY source_id) AS total
+    FROM source_contents
+) v
+WHERE sc.id = v.id;
+
+ALTER TABLE source_contents
+    ALTER COLUMN version SET NOT NULL,
+    ALTER COLUMN byte_length SET NOT NULL,
+    ADD CONSTRAINT source_contents_source_id_version_key UNIQUE (source_id, version);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Every writer (this worker or the API saving a note) gets the next version
+-- number and makes the new row the only current one. Writers of the same
+-- source are serialized on its sources row, so no two get the same number.
+CREATE OR REPLACE FUNCTION source_contents_new_version() RETURNS trigger AS $$
+BEGIN
+    PERFORM 1 FROM sources WHERE id = NEW.source_id FOR NO KEY UPDATE;
+
+    IF NEW.version IS NULL THEN
+        SELECT COALESCE(MAX(version), 0) + 1 INTO NEW.version
+        FROM source_contents
+        WHERE source_id = NEW.source_id;
+    END IF;
+
+    NEW.byte_length := octet_length(NEW.content_text);
+
+    IF NEW.is_current THEN
+        UPDATE source_contents
+        SET is_current = false
+        WHERE source_id = NEW.source_id AND is_current AND content_hash <> NEW.content_hash;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER source_contents_new_version
+BEFORE INSERT ON source_contents
+FOR EACH ROW EXECUTE FUNCTION source_contents_new_version();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS source_contents_new_version ON source_contents;
+DROP FUNCTION IF EXISTS source_contents_new_version();
+ALTER TABLE source_contents
+    DROP CONSTRAINT IF EXISTS source_contents_source_id_version_key,
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS byte_length,
+    DROP COLUMN IF EXISTS is_current;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+-- Jobs aborted by a cancel message, as opposed to ones that failed
+ALTER TYPE source_status ADD VALUE IF NOT EXISTS 'cancelled';
+ALTER TYPE processing_job_status ADD VALUE IF NOT EXISTS 'cancelled';
+
+-- When a cancel was requested for the source. Jobs whose message was
+-- published before it are stopped, wherever they run or wait.
+ALTER TABLE processing_jobs
+    ADD COLUMN IF NOT EXISTS cancel_requested_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_cancel_requested_at
+    ON processing_jobs(source_id, cancel_requested_at)
+    WHERE cancel_requested_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_processing_jobs_cancel_requested_at;
+ALTER TABLE processing_jobs
+    DROP COLUMN IF EXISTS cancel_requested_at;
+
+-- Postgres cannot drop an enum value, fold cancelled rows into failed instead
+UPDATE sources SET status = 'failed' WHERE status = 'cancelled';
+UPDATE processing_jobs SET status = 'failed' WHERE status = 'cancelled';
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+------------------------------------------------
+-- PARENT CHUNKS (larger sections the indexed chunks were split from)
+------------------------------------------------
+CREATE TABLE IF NOT EXISTS source_parent_chunks (
+    source_id UUID NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    parent_index INT NOT NULL,
+
+    -- Section text and its context (e.g. heading path), as handed to the chat model
+    content TEXT NOT NULL,
+    context TEXT NOT NULL DEFAULT '',
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (source_id, parent_index)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS source_parent_chunks;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+------------------------------------------------
+-- PAGE BOUNDARIES OF PARSED DOCUMENTS
+------------------------------------------------
+-- Character offset into content_text at which each page (or slide) starts,
+-- NULL for content that has no pages
+ALTER TABLE source_contents
+    ADD COLUMN page_starts INT[];
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE source_contents
+    DROP COLUMN IF EXISTS page_starts;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+------------------------------------------------
+-- NEAR-DUPLICATE CHUNK REMOVAL
+------------------------------------------------
+-- Simhash of each indexed chunk's text, compared against when duplicates are
+-- looked for across the user's sources
+ALTER TABLE source_chunk_manifests
+    ADD COLUMN simhash BIGINT;
+
+-- Chunks dropped as duplicates before embedding
+ALTER TABLE processing_jobs
+    ADD COLUMN duplicate_count INT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE processing_jobs
+    DROP COLUMN IF EXISTS duplicate_count;
+ALTER TABLE source_chunk_manifests
+    DROP COLUMN IF EXISTS simhash;
+-- +goose StatementEnd
@@ -2,14 +2,24 @@ package gemini
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"sort"
+	"strings"
 
 	"google.golang.org/genai"
 )
 
 const EmbeddingModel = "gemini-embedding-001"
 
+// MaxInputTokens is the most tokens EmbeddingModel accepts in a single input
+const MaxInputTokens = 2048
+
+// MaxBatchSize is the most inputs EmbeddingModel accepts in a single request
+const MaxBatchSize = 100
+
 var outputDimensionality int32 = 768
 
 type Client struct {
@@ -55,3 +65,118 @@ func (c *Client) GenerateEmbedding(ctx context.Context, text string) ([]float32,
 
 	return result.Embeddings[0].Values, nil
 }
+
+// EmbeddingErrors reports the inputs of a GenerateEmbeddings call that could
+// not be embedded, keyed by their index in the input
+type EmbeddingErrors map[int]error
+
+func (e EmbeddingErrors) Error() string {
+	idxs := make([]int, 0, len(e))
+	for i := range e {
+		idxs = append(idxs, i)
+	}
+	sort.Ints(idxs)
+
+	msgs := make([]string, len(idxs))
+	for n, i := range idxs {
+		msgs[n] = fmt.Sprintf("input %d: %v", i, e[i])
+	}
+	return fmt.Sprintf("failed to embed %d inputs: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// GenerateEmbeddings embeds texts with one request per MaxBatchSize inputs and
+// returns their embeddings in the same order. When some inputs fail, the
+// embeddings of the others are still returned, the failed ones are left nil
+// and the error is an EmbeddingErrors naming them. A batch the API rejects as
+// invalid is retried one input at a time so a single bad input doesn't fail
+// its neighbours. Any other error is returned as is once ctx is done.
+func (c *Client) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	failed := make(EmbeddingErrors)
+
+	for start := 0; start < len(texts); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(texts))
+
+		var idxs []int
+		var contents []*genai.Content
+		for i := start; i < end; i++ {
+			if texts[i] == "" {
+				failed[i] = fmt.Errorf("text cannot be empty")
+				continue
+			}
+			idxs = append(idxs, i)
+			contents = append(contents, genai.NewContentFromText(texts[i], genai.RoleUser))
+		}
+		if len(contents) == 0 {
+			continue
+		}
+
+		values, err := c.embed(ctx, contents)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !invalidRequest(err) || len(contents) == 1 {
+				for _, i := range idxs {
+					failed[i] = err
+				}
+				continue
+			}
+
+			// Find the inputs that made the batch invalid
+			for n, i := range idxs {
+				one, err := c.embed(ctx, contents[n:n+1])
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					failed[i] = err
+					continue
+				}
+				embeddings[i] = one[0]
+			}
+			continue
+		}
+
+		for n, i := range idxs {
+			embeddings[i] = values[n]
+		}
+	}
+
+	if len(failed) > 0 {
+		return embeddings, failed
+	}
+	return embeddings, nil
+}
+
+// embed sends contents in a single request and returns one embedding per content
+func (c *Client) embed(ctx context.Context, contents []*genai.Content) ([][]float32, error) {
+	result, err := c.client.Models.EmbedContent(ctx,
+		EmbeddingModel,
+		contents,
+		&genai.EmbedContentConfig{OutputDimensionality: &outputDimensionality},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed content: %w", err)
+	}
+
+	if len(result.Embeddings) != len(contents) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(result.Embeddings), len(contents))
+	}
+
+	values := make([][]float32, len(contents))
+	for i, embedding := range result.Embeddings {
+		values[i] = embedding.Values
+	}
+	return values, nil
+}
+
+// invalidRequest reports whether the API rejected the request itself, as
+// opposed to failing to serve it (rate limits, outages)
+func invalidRequest(err error) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusBadRequest
+}
@@ -81,6 +81,12 @@ type markdownResponse struct {
 	Markdown string `json:"markdown"`
 }
 
+// Page is one page of a parsed document, or one slide of a presentation
+type Page struct {
+	Number   int    `json:"page"`
+	Markdown string `json:"md"`
+}
+
 type apiError struct {
 	Detail string `json:"detail"`
 }
@@ -96,6 +102,46 @@ func (c *Client) ParseFile(ctx context.Context, filePath string) (string, error)
 	return c.ParseReader(ctx, file, filepath.Base(filePath))
 }
 
+// ParseFilePages uploads a file from disk and returns the extracted markdown page by page
+func (c *Client) ParseFilePages(ctx context.Context, filePath string) ([]Page, error) {
+	var pages []Page
+	err := c.ParseFilePagesFunc(ctx, filePath, func(page Page) error {
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// ParseFilePagesFunc uploads a file from disk and calls fn with every page of
+// the extracted markdown, in order, as it is read from the result. Neither the
+// file nor the result is held in memory at once, so documents of any length
+// can be parsed. An error from fn stops reading and is returned.
+func (c *Client) ParseFilePagesFunc(ctx context.Context, filePath string, fn func(Page) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	jobID, err := c.upload(ctx, file, filepath.Base(filePath))
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+
+	if err := c.waitForJob(ctx, jobID); err != nil {
+		return fmt.Errorf("job failed: %w", err)
+	}
+
+	if err := c.eachPage(ctx, jobID, fn); err != nil {
+		return fmt.Errorf("failed to get result: %w", err)
+	}
+
+	return nil
+}
+
 // ParseBytes parses document content from bytes and returns the extracted markdown
 func (c *Client) ParseBytes(ctx context.Context, data []byte, filename string) (string, error) {
 	return c.ParseReader(ctx, bytes.NewReader(data), filename)
@@ -163,26 +209,38 @@ func (c *Client) ParseURL(ctx context.Context, documentURL string) (string, erro
 }
 
 func (c *Client) upload(ctx context.Context, reader io.Reader, filename string) (string, error) {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	part, err := writer.CreateFormFile("file", filename)
-	if err != nil {
+	// Only the multipart framing is built in memory, the file is read as it is sent
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	if _, err := writer.CreateFormFile("file", filename); err != nil {
 		return "", err
 	}
-
-	if _, err := io.Copy(part, reader); err != nil {
+	head := bytes.Clone(form.Bytes())
+	form.Reset()
+	if err := writer.Close(); err != nil {
 		return "", err
 	}
+	tail := form.Bytes()
 
-	if err := writer.Close(); err != nil {
+	size, err := readerSize(reader)
+	if err != nil {
 		return "", err
 	}
+	if size < 0 {
+		// Unknown length, buffer it so the request still has a Content-Length
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		reader, size = bytes.NewReader(data), int64(len(data))
+	}
 
+	body := io.MultiReader(bytes.NewReader(head), reader, bytes.NewReader(tail))
 	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/upload", body)
 	if err != nil {
 		return "", err
 	}
+	req.ContentLength = int64(len(head)) + size + int64(len(tail))
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
@@ -288,6 +346,82 @@ func (c *Client) getResult(ctx context.Context, jobID string) (string, error) {
 	return res.Markdown, nil
 }
 
+// readerSize returns how many bytes are left to read from r, or -1 if that can't be told
+func readerSize(r io.Reader) (int64, error) {
+	switch r := r.(type) {
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil {
+			return 0, err
+		}
+		pos, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size() - pos, nil
+	case interface{ Len() int }:
+		return int64(r.Len()), nil
+	default:
+		return -1, nil
+	}
+}
+
+// eachPage decodes the pages of the job's JSON result one at a time, skipping
+// the other fields, and calls fn with each of them
+func (c *Client) eachPage(ctx context.Context, jobID string, fn func(Page) error) error {
+	url := fmt.Sprintf("%s/job/%s/result/json", baseURL, jobID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	if _, err := dec.Token(); err != nil { // {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "pages" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := dec.Token(); err != nil { // [
+			return err
+		}
+		for dec.More() {
+			var page Page
+			if err := dec.Decode(&page); err != nil {
+				return err
+			}
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // ]
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Client) parseError(resp *http.Response) error {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -138,6 +138,93 @@ func (c *Client) UpsertBatch(ctx context.Context, vectors []Vector, batchSize in
 	return totalCount, nil
 }
 
+// FetchWithNamespace returns the values of the vectors with the given IDs in a
+// specific namespace, keyed by ID (100 per request). IDs that don't exist are left out.
+func (c *Client) FetchWithNamespace(ctx context.Context, namespace string, ids []string) (map[string][]float32, error) {
+	namespacedConn := c.idxConn.WithNamespace(namespace)
+
+	values := make(map[string][]float32, len(ids))
+	for i := 0; i < len(ids); i += 100 {
+		end := i + 100
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		res, err := namespacedConn.FetchVectors(ctx, ids[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch vectors from namespace %s: %w", namespace, err)
+		}
+		for id, v := range res.Vectors {
+			if v != nil && v.Values != nil {
+				values[id] = *v.Values
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// ListIDsByPrefix returns every vector ID in the namespace that starts with prefix
+func (c *Client) ListIDsByPrefix(ctx context.Context, namespace, prefix string) ([]string, error) {
+	namespacedConn := c.idxConn.WithNamespace(namespace)
+
+	var ids []string
+	var token *string
+	for {
+		res, err := namespacedConn.ListVectors(ctx, &pinecone.ListVectorsRequest{
+			Prefix:          &prefix,
+			PaginationToken: token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list vectors with prefix %s: %w", prefix, err)
+		}
+
+		for _, id := range res.VectorIds {
+			if id != nil {
+				ids = append(ids, *id)
+			}
+		}
+
+		if res.NextPaginationToken == nil || *res.NextPaginationToken == "" {
+			return ids, nil
+		}
+		token = res.NextPaginationToken
+	}
+}
+
+// DeleteWithNamespace deletes vectors by ID from a specific namespace (max 1000 per request)
+func (c *Client) DeleteWithNamespace(ctx context.Context, namespace string, ids []string) error {
+	namespacedConn := c.idxConn.WithNamespace(namespace)
+
+	for i := 0; i < len(ids); i += 1000 {
+		end := i + 1000
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if err := namespacedConn.DeleteVectorsById(ctx, ids[i:end]); err != nil {
+			return fmt.Errorf("failed to delete vectors from namespace %s: %w", namespace, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteByPrefix deletes every vector in the namespace whose ID starts with prefix.
+// Returns how many vectors were deleted.
+func (c *Client) DeleteByPrefix(ctx context.Context, namespace, prefix string) (int, error) {
+	ids, err := c.ListIDsByPrefix(ctx, namespace, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.DeleteWithNamespace(ctx, namespace, ids); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
 // Close closes the index connection
 func (c *Client) Close() error {
 	if c.idxConn != nil {
@@ -7,7 +7,6 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"path"
 	"strings"
 	"time"
 
@@ -16,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type Client struct {
@@ -92,16 +92,22 @@ func (c *Client) DownloadToTemp(ctx context.Context, bucket, key string) (string
 	return tempFile.Name(), nil
 }
 
-// UploadFromURL downloads an image from a URL and uploads it to S3
+// UploadFromURL downloads an image from a URL and uploads it to S3 under key,
+// replacing whatever an earlier upload put there.
 // Returns the S3 URL of the uploaded image
-func (c *Client) UploadFromURL(ctx context.Context, imageURL, keyPrefix string) (string, error) {
+func (c *Client) UploadFromURL(ctx context.Context, imageURL, key string) (string, error) {
 	if imageURL == "" {
 		return "", nil
 	}
 
-	// Download image from URL
+	// Download image from URL, giving up early if the job is cancelled
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build image request: %w", err)
+	}
+
 	httpClient := &http.Client{Timeout: 30 * time.Second}
-	resp, err := httpClient.Get(imageURL)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to download image: %w", err)
 	}
@@ -123,25 +129,16 @@ func (c *Client) UploadFromURL(ctx context.Context, imageURL, keyPrefix string)
 		contentType = http.DetectContentType(body)
 	}
 
-	ext := ".jpg"
-	if strings.Contains(contentType, "png") {
-		ext = ".png"
-	} else if strings.Contains(contentType, "gif") {
-		ext = ".gif"
-	} else if strings.Contains(contentType, "webp") {
-		ext = ".webp"
-	}
-
-	// Create S3 key: keyPrefix/timestamp.ext
-	key := path.Join(keyPrefix, fmt.Sprintf("%d%s", time.Now().UnixNano(), ext))
-
-	// Upload to S3
+	// Upload to S3. The key carries no extension, so an image of another type
+	// still overwrites the previous one, and the URL stays the same, so caches
+	// must revalidate it.
 	_, err = c.uploader.Upload(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(c.bucketName),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(body),
-		ContentType: aws.String(contentType),
-		ACL:         "public-read", // Make images publicly accessible
+		Bucket:       aws.String(c.bucketName),
+		Key:          aws.String(key),
+		Body:         bytes.NewReader(body),
+		ContentType:  aws.String(contentType),
+		CacheControl: aws.String("no-cache"),
+		ACL:          "public-read", // Make images publicly accessible
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to upload image to S3: %w", err)
@@ -154,6 +151,73 @@ func (c *Client) UploadFromURL(ctx context.Context, imageURL, keyPrefix string)
 	return s3URL, nil
 }
 
+// DeleteObject removes a single object from the configured bucket.
+// Deleting a key that doesn't exist is not an error.
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	_, err := c.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeletePrefix removes every object under prefix in the configured bucket
+// and returns how many were deleted
+func (c *Client) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	paginator := s3.NewListObjectsV2Paginator(c.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucketName),
+		Prefix: aws.String(prefix),
+	})
+
+	deleted := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		// Each page holds at most 1000 keys, which is also the DeleteObjects limit
+		objects := make([]types.ObjectIdentifier, len(page.Contents))
+		for i, obj := range page.Contents {
+			objects[i] = types.ObjectIdentifier{Key: obj.Key}
+		}
+
+		out, err := c.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(c.bucketName),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete objects under %s: %w", prefix, err)
+		}
+		// The request succeeds even if single objects could not be deleted
+		deleted += len(objects) - len(out.Errors)
+		if len(out.Errors) > 0 {
+			first := out.Errors[0]
+			return deleted, fmt.Errorf("failed to delete %d objects under %s, first %s: %s",
+				len(out.Errors), prefix, aws.ToString(first.Key), aws.ToString(first.Message))
+		}
+	}
+
+	return deleted, nil
+}
+
+// KeyFromURL extracts the object key from a public URL returned by UploadFromURL.
+// Returns false if the URL doesn't point into the configured bucket.
+func (c *Client) KeyFromURL(objectURL string) (string, bool) {
+	endpoint := strings.TrimPrefix(c.endpoint, "https://")
+	base := fmt.Sprintf("https://%s.%s/", c.bucketName, endpoint)
+	if !strings.HasPrefix(objectURL, base) {
+		return "", false
+	}
+	return strings.TrimPrefix(objectURL, base), true
+}
+
 // GetS3Client returns the underlying S3 client
 func (c *Client) GetS3Client() *s3.Client {
 	return c.s3Client
@@ -95,6 +95,51 @@ func (ns NullOauthProvider) Value() (driver.Value, error) {
 	return string(ns.OauthProvider), nil
 }
 
+type ProcessingJobStatus string
+
+const (
+	ProcessingJobStatusRunning   ProcessingJobStatus = "running"
+	ProcessingJobStatusSucceeded ProcessingJobStatus = "succeeded"
+	ProcessingJobStatusFailed    ProcessingJobStatus = "failed"
+	ProcessingJobStatusSkipped   ProcessingJobStatus = "skipped"
+	ProcessingJobStatusCancelled ProcessingJobStatus = "cancelled"
+)
+
+func (e *ProcessingJobStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ProcessingJobStatus(s)
+	case string:
+		*e = ProcessingJobStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ProcessingJobStatus: %T", src)
+	}
+	return nil
+}
+
+type NullProcessingJobStatus struct {
+	ProcessingJobStatus ProcessingJobStatus
+	Valid               bool // Valid is true if ProcessingJobStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullProcessingJobStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.ProcessingJobStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ProcessingJobStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullProcessingJobStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ProcessingJobStatus), nil
+}
+
 type SourceStatus string
 
 const (
@@ -102,6 +147,7 @@ const (
 	SourceStatusProcessing SourceStatus = "processing"
 	SourceStatusIndexed    SourceStatus = "indexed"
 	SourceStatusFailed     SourceStatus = "failed"
+	SourceStatusCancelled  SourceStatus = "cancelled"
 )
 
 func (e *SourceStatus) Scan(src interface{}) error {
@@ -232,6 +278,23 @@ type PasswordResetToken struct {
 	CreatedAt pgtype.Timestamptz
 }
 
+type ProcessingJob struct {
+	ID                pgtype.UUID
+	SourceID          pgtype.UUID
+	Action            string
+	Attempt           int32
+	Status            ProcessingJobStatus
+	Stage             pgtype.Text
+	StageStartedAt    []byte
+	ChunkCount        int32
+	VectorCount       int32
+	LastError         []byte
+	StartedAt         pgtype.Timestamptz
+	FinishedAt        pgtype.Timestamptz
+	DuplicateCount    int32
+	CancelRequestedAt pgtype.Timestamptz
+}
+
 type Session struct {
 	ID         pgtype.UUID
 	UserID     pgtype.UUID
@@ -258,12 +321,33 @@ type Source struct {
 	ImageUrl     pgtype.Text
 }
 
+type SourceChunkManifest struct {
+	SourceID   pgtype.UUID
+	ChunkIndex int32
+	TextHash   string
+	MetaHash   string
+	UpdatedAt  pgtype.Timestamptz
+	Simhash    pgtype.Int8
+}
+
 type SourceContent struct {
 	ID          pgtype.UUID
 	SourceID    pgtype.UUID
 	ContentText string
 	ContentHash string
 	CreatedAt   pgtype.Timestamptz
+	Version     int32
+	ByteLength  int32
+	IsCurrent   bool
+	PageStarts  []int32
+}
+
+type SourceParentChunk struct {
+	SourceID    pgtype.UUID
+	ParentIndex int32
+	Content     string
+	Context     string
+	UpdatedAt   pgtype.Timestamptz
 }
 
 type User struct {
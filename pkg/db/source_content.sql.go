@@ -11,23 +11,115 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const appendSourceContent = `-- name: AppendSourceContent :exec
+UPDATE source_contents
+SET content_text = content_text || $1::text,
+    byte_length = byte_length + octet_length($1::text)
+WHERE id = $2
+`
+
+type AppendSourceContentParams struct {
+	Piece string
+	ID    pgtype.UUID
+}
+
+func (q *Queries) AppendSourceContent(ctx context.Context, arg AppendSourceContentParams) error {
+	_, err := q.db.Exec(ctx, appendSourceContent, arg.Piece, arg.ID)
+	return err
+}
+
+const createEmptySourceContent = `-- name: CreateEmptySourceContent :one
+INSERT INTO source_contents (source_id, content_text, content_hash, page_starts)
+VALUES ($1, '', $2, $3)
+ON CONFLICT (source_id, content_hash)
+DO UPDATE SET version = EXCLUDED.version, content_text = '', byte_length = 0,
+    page_starts = EXCLUDED.page_starts, is_current = true, created_at = NOW()
+WHERE NOT source_contents.is_current
+RETURNING id
+`
+
+type CreateEmptySourceContentParams struct {
+	SourceID    pgtype.UUID
+	ContentHash string
+	PageStarts  []int32
+}
+
+// CreateSourceContent for text streamed in with AppendSourceContent, in the same
+// transaction. No row is returned when the text is already the current version.
+func (q *Queries) CreateEmptySourceContent(ctx context.Context, arg CreateEmptySourceContentParams) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, createEmptySourceContent, arg.SourceID, arg.ContentHash, arg.PageStarts)
+	var id pgtype.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
 const createSourceContent = `-- name: CreateSourceContent :exec
-INSERT INTO source_contents (source_id, content_text)
-VALUES ($1, $2)
+INSERT INTO source_contents (source_id, content_text, content_hash, page_starts)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (source_id, content_hash)
+DO UPDATE SET version = EXCLUDED.version, content_text = EXCLUDED.content_text, byte_length = EXCLUDED.byte_length,
+    page_starts = EXCLUDED.page_starts, is_current = true, created_at = NOW()
+WHERE NOT source_contents.is_current
 `
 
 type CreateSourceContentParams struct {
 	SourceID    pgtype.UUID
 	ContentText string
+	ContentHash string
+	PageStarts  []int32
 }
 
+// version and byte_length are filled in by the source_contents_new_version trigger.
+// Text that an older, no longer current version had moves that row up to a new
+// version number and makes it current again, so A -> B -> A ends with A as the
+// latest version. Saving the current text again changes nothing.
 func (q *Queries) CreateSourceContent(ctx context.Context, arg CreateSourceContentParams) error {
-	_, err := q.db.Exec(ctx, createSourceContent, arg.SourceID, arg.ContentText)
+	_, err := q.db.Exec(ctx, createSourceContent,
+		arg.SourceID,
+		arg.ContentText,
+		arg.ContentHash,
+		arg.PageStarts,
+	)
 	return err
 }
 
+const deleteSourceContentsBySourceID = `-- name: DeleteSourceContentsBySourceID :exec
+DELETE FROM source_contents WHERE source_id = $1
+`
+
+func (q *Queries) DeleteSourceContentsBySourceID(ctx context.Context, sourceID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteSourceContentsBySourceID, sourceID)
+	return err
+}
+
+const getLatestSourceContent = `-- name: GetLatestSourceContent :one
+SELECT id, source_id, content_text, content_hash, created_at, version, byte_length, is_current, page_starts FROM source_contents
+WHERE source_id = $1
+ORDER BY is_current DESC, version DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestSourceContent(ctx context.Context, sourceID pgtype.UUID) (SourceContent, error) {
+	row := q.db.QueryRow(ctx, getLatestSourceContent, sourceID)
+	var i SourceContent
+	err := row.Scan(
+		&i.ID,
+		&i.SourceID,
+		&i.ContentText,
+		&i.ContentHash,
+		&i.CreatedAt,
+		&i.Version,
+		&i.ByteLength,
+		&i.IsCurrent,
+		&i.PageStarts,
+	)
+	return i, err
+}
+
 const getSourceContentBySourceID = `-- name: GetSourceContentBySourceID :many
-SELECT id, source_id, content_text, content_hash, created_at FROM source_contents WHERE source_id = $1
+SELECT id, source_id, content_text, content_hash, created_at, version, byte_length, is_current, page_starts FROM source_contents
+WHERE source_id = $1
+ORDER BY version DESC
 `
 
 func (q *Queries) GetSourceContentBySourceID(ctx context.Context, sourceID pgtype.UUID) ([]SourceContent, error) {
@@ -45,6 +137,82 @@ func (q *Queries) GetSourceContentBySourceID(ctx context.Context, sourceID pgtyp
 			&i.ContentText,
 			&i.ContentHash,
 			&i.CreatedAt,
+			&i.Version,
+			&i.ByteLength,
+			&i.IsCurrent,
+			&i.PageStarts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSourceContentByVersion = `-- name: GetSourceContentByVersion :one
+SELECT id, source_id, content_text, content_hash, created_at, version, byte_length, is_current, page_starts FROM source_contents
+WHERE source_id = $1 AND version = $2
+`
+
+type GetSourceContentByVersionParams struct {
+	SourceID pgtype.UUID
+	Version  int32
+}
+
+func (q *Queries) GetSourceContentByVersion(ctx context.Context, arg GetSourceContentByVersionParams) (SourceContent, error) {
+	row := q.db.QueryRow(ctx, getSourceContentByVersion, arg.SourceID, arg.Version)
+	var i SourceContent
+	err := row.Scan(
+		&i.ID,
+		&i.SourceID,
+		&i.ContentText,
+		&i.ContentHash,
+		&i.CreatedAt,
+		&i.Version,
+		&i.ByteLength,
+		&i.IsCurrent,
+		&i.PageStarts,
+	)
+	return i, err
+}
+
+const listSourceContentVersions = `-- name: ListSourceContentVersions :many
+SELECT id, source_id, version, content_hash, byte_length, is_current, created_at
+FROM source_contents
+WHERE source_id = $1
+ORDER BY version DESC
+`
+
+type ListSourceContentVersionsRow struct {
+	ID          pgtype.UUID
+	SourceID    pgtype.UUID
+	Version     int32
+	ContentHash string
+	ByteLength  int32
+	IsCurrent   bool
+	CreatedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) ListSourceContentVersions(ctx context.Context, sourceID pgtype.UUID) ([]ListSourceContentVersionsRow, error) {
+	rows, err := q.db.Query(ctx, listSourceContentVersions, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSourceContentVersionsRow
+	for rows.Next() {
+		var i ListSourceContentVersionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SourceID,
+			&i.Version,
+			&i.ContentHash,
+			&i.ByteLength,
+			&i.IsCurrent,
+			&i.CreatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -55,3 +223,19 @@ func (q *Queries) GetSourceContentBySourceID(ctx context.Context, sourceID pgtyp
 	}
 	return items, nil
 }
+
+const setCurrentSourceContentVersion = `-- name: SetCurrentSourceContentVersion :exec
+UPDATE source_contents
+SET is_current = (version = $2)
+WHERE source_id = $1
+`
+
+type SetCurrentSourceContentVersionParams struct {
+	SourceID pgtype.UUID
+	Version  int32
+}
+
+func (q *Queries) SetCurrentSourceContentVersion(ctx context.Context, arg SetCurrentSourceContentVersionParams) error {
+	_, err := q.db.Exec(ctx, setCurrentSourceContentVersion, arg.SourceID, arg.Version)
+	return err
+}
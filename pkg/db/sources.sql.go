@@ -37,6 +37,102 @@ func (q *Queries) GetSourceByID(ctx context.Context, id pgtype.UUID) (Source, er
 	return i, err
 }
 
+const listSourcesByCollection = `-- name: ListSourcesByCollection :many
+SELECT id, user_id, collection_id, type, status, title, original_url, s3_bucket, s3_key, content_hash, created_at, image_url
+FROM sources
+WHERE collection_id = $1
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListSourcesByCollection(ctx context.Context, collectionID pgtype.UUID) ([]Source, error) {
+	rows, err := q.db.Query(ctx, listSourcesByCollection, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Source
+	for rows.Next() {
+		var i Source
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CollectionID,
+			&i.Type,
+			&i.Status,
+			&i.Title,
+			&i.OriginalUrl,
+			&i.S3Bucket,
+			&i.S3Key,
+			&i.ContentHash,
+			&i.CreatedAt,
+			&i.ImageUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSourcesByUser = `-- name: ListSourcesByUser :many
+SELECT id, user_id, collection_id, type, status, title, original_url, s3_bucket, s3_key, content_hash, created_at, image_url
+FROM sources
+WHERE user_id = $1
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListSourcesByUser(ctx context.Context, userID pgtype.UUID) ([]Source, error) {
+	rows, err := q.db.Query(ctx, listSourcesByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Source
+	for rows.Next() {
+		var i Source
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CollectionID,
+			&i.Type,
+			&i.Status,
+			&i.Title,
+			&i.OriginalUrl,
+			&i.S3Bucket,
+			&i.S3Key,
+			&i.ContentHash,
+			&i.CreatedAt,
+			&i.ImageUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateSourceContentHash = `-- name: UpdateSourceContentHash :exec
+UPDATE sources
+SET content_hash = $2
+WHERE id = $1
+`
+
+type UpdateSourceContentHashParams struct {
+	ID          pgtype.UUID
+	ContentHash pgtype.Text
+}
+
+func (q *Queries) UpdateSourceContentHash(ctx context.Context, arg UpdateSourceContentHashParams) error {
+	_, err := q.db.Exec(ctx, updateSourceContentHash, arg.ID, arg.ContentHash)
+	return err
+}
+
 const updateSourceStatus = `-- name: UpdateSourceStatus :exec
 UPDATE sources 
 SET status = $2
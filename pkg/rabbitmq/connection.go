@@ -1,9 +1,59 @@
 package rabbitmq
 
-import "github.com/rabbitmq/amqp091-go"
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// ErrClientClosed is returned once the client has been closed for good
+var ErrClientClosed = errors.New("rabbitmq client closed")
+
+// ConnState is the state of the supervised broker connection
+type ConnState int32
+
+const (
+	StateConnecting ConnState = iota
+	StateConnected
+	StateReconnecting
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// RabbitClient owns the broker connection and transparently re-dials it
+// with backoff whenever the broker drops it
 type RabbitClient struct {
-	Conn *amqp091.Connection
+	url   string
+	state atomic.Int32
+
+	mu    sync.RWMutex
+	conn  *amqp091.Connection
+	ready chan struct{} // closed while a connection is up
+	done  chan struct{} // closed by Close
 }
 
 func NewRabbitClient(url string) (*RabbitClient, error) {
@@ -11,14 +61,124 @@ func NewRabbitClient(url string) (*RabbitClient, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &RabbitClient{
-		Conn: conn,
-	}, nil
+
+	rc := &RabbitClient{
+		url:   url,
+		conn:  conn,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	close(rc.ready)
+	rc.state.Store(int32(StateConnected))
+
+	go rc.watch(conn)
+	return rc, nil
+}
+
+// State reports the current connection state, e.g. for health checks
+func (rc *RabbitClient) State() ConnState {
+	return ConnState(rc.state.Load())
+}
+
+// IsConnected reports whether the client currently holds a live connection
+func (rc *RabbitClient) IsConnected() bool {
+	return rc.State() == StateConnected
+}
+
+// Channel opens a channel on the current connection, waiting for a
+// reconnect to finish if the connection is down
+func (rc *RabbitClient) Channel(ctx context.Context) (*amqp091.Channel, error) {
+	rc.mu.RLock()
+	ready := rc.ready
+	rc.mu.RUnlock()
+
+	select {
+	case <-ready:
+	case <-rc.done:
+		return nil, ErrClientClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	rc.mu.RLock()
+	conn := rc.conn
+	rc.mu.RUnlock()
+
+	return conn.Channel()
+}
+
+// watch waits for the connection to drop and re-dials until it succeeds or the client is closed
+func (rc *RabbitClient) watch(conn *amqp091.Connection) {
+	for {
+		closed := conn.NotifyClose(make(chan *amqp091.Error, 1))
+		reason := <-closed
+
+		select {
+		case <-rc.done:
+			return
+		default:
+		}
+
+		log.Printf("RabbitMQ connection lost: %v", reason)
+
+		rc.mu.Lock()
+		rc.ready = make(chan struct{})
+		rc.mu.Unlock()
+		rc.state.Store(int32(StateReconnecting))
+
+		conn = rc.redial()
+		if conn == nil {
+			return
+		}
+
+		rc.mu.Lock()
+		rc.conn = conn
+		close(rc.ready)
+		rc.mu.Unlock()
+		rc.state.Store(int32(StateConnected))
+
+		log.Println("RabbitMQ connection re-established")
+	}
+}
+
+// redial keeps dialing with exponential backoff. Returns nil if the client is closed meanwhile.
+func (rc *RabbitClient) redial() *amqp091.Connection {
+	delay := minReconnectDelay
+	for {
+		select {
+		case <-rc.done:
+			return nil
+		case <-time.After(delay):
+		}
+
+		conn, err := amqp091.Dial(rc.url)
+		if err == nil {
+			return conn
+		}
+
+		log.Printf("RabbitMQ reconnect failed, retrying in %s: %v", delay, err)
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
 }
 
 func (rc *RabbitClient) Close() error {
-	if rc.Conn != nil {
-		return rc.Conn.Close()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	select {
+	case <-rc.done:
+		return nil
+	default:
+	}
+
+	close(rc.done)
+	rc.state.Store(int32(StateClosed))
+
+	if rc.conn != nil && !rc.conn.IsClosed() {
+		return rc.conn.Close()
 	}
 	return nil
 }
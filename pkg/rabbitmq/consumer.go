@@ -1,15 +1,67 @@
 package rabbitmq
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	// RetryCountHeader carries how many times a message has been retried
+	RetryCountHeader = "x-retry-count"
+
+	defaultRetryBaseDelay = 5 * time.Second
+	defaultConcurrency    = 1
+	publishTimeout        = 5 * time.Second
+
+	// requeueGrace is how long Shutdown waits for cancelled jobs to hand their
+	// deliveries back before closing the channel, which requeues anything left
+	requeueGrace = 10 * time.Second
+)
+
+// Result tells the consumer how to settle a delivery once the handler returns
+type Result int
+
+const (
+	// Ack removes the message from the queue
+	Ack Result = iota
+	// Retry schedules the message for another attempt after a backoff delay
+	Retry
+	// Reject sends the message straight to the dead-letter queue
+	Reject
+	// Requeue puts the message back on the queue right away without counting
+	// an attempt, e.g. when a job was interrupted by shutdown
+	Requeue
+)
+
+// Handler processes a single delivery and reports how it should be settled.
+// The context is cancelled when the job has to be abandoned during shutdown.
+type Handler func(context.Context, amqp091.Delivery) Result
+
 type Consumer struct {
-	ch       *amqp091.Channel
-	exchange string
-	queue    string
+	client      *RabbitClient
+	republisher *Publisher // moves deliveries to the retry and dead-letter queues
+	cfg         ConsumerConfig
+	maxRetries  int
+	concurrency int
+	prefetch    int
+
+	tag        string
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+	stopped    chan struct{} // closed once every worker has returned
+
+	mu      sync.RWMutex
+	ch      *amqp091.Channel
+	queue   string
+	closing atomic.Bool
 }
 
 type ConsumerConfig struct {
@@ -17,15 +69,110 @@ type ConsumerConfig struct {
 	ExchangeType string
 	Queue        string
 	RoutingKey   string
+
+	// MaxRetries is how many times a failed message is retried before it is dead-lettered
+	MaxRetries int
+	// RetryBaseDelay is the delay before the first retry, doubled on every attempt
+	RetryBaseDelay time.Duration
+
+	// ControlRoutingKey, when set, routes messages to a control queue that is
+	// consumed next to the main one, so they never wait behind jobs. Control
+	// deliveries are handled as they arrive and must map to KeyInline.
+	ControlRoutingKey string
+
+	// Concurrency is the number of deliveries handled in parallel
+	Concurrency int
+	// Prefetch is how many unacked deliveries the broker may push to this consumer.
+	// It should be at least Concurrency, otherwise workers sit idle.
+	Prefetch int
 }
 
-func NewChannel(conn *amqp091.Connection, cfg ConsumerConfig) (*Consumer, error) {
-	ch, err := conn.Channel()
+func NewChannel(client *RabbitClient, cfg ConsumerConfig) (*Consumer, error) {
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	prefetch := cfg.Prefetch
+	if prefetch <= 0 {
+		prefetch = concurrency
+	}
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = defaultRetryBaseDelay
+	}
+
+	// Confirmed publishes through the default exchange, so a delivery is only
+	// acked once its copy is safely on the retry or dead-letter queue
+	republisher, err := NewPublisher(client, PublisherConfig{})
 	if err != nil {
+		return nil, fmt.Errorf("failed to open republish channel: %w", err)
+	}
+
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+
+	c := &Consumer{
+		client:      client,
+		republisher: republisher,
+		cfg:         cfg,
+		maxRetries:  cfg.MaxRetries,
+		concurrency: concurrency,
+		prefetch:    prefetch,
+		tag:         fmt.Sprintf("%s-%d", cfg.Queue, os.Getpid()),
+		jobCtx:      jobCtx,
+		cancelJobs:  cancelJobs,
+		stopped:     make(chan struct{}),
+	}
+
+	if err := c.open(context.Background()); err != nil {
+		republisher.Close()
 		return nil, err
 	}
+	return c, nil
+}
+
+// open creates a fresh channel and (re-)declares the topology on it.
+// Declarations are idempotent, so this is safe to run after every reconnect.
+func (c *Consumer) open(ctx context.Context) error {
+	ch, err := c.client.Channel(ctx)
+	if err != nil {
+		return err
+	}
+
+	queue, err := declareTopology(ch, c.cfg)
+	if err != nil {
+		ch.Close()
+		return err
+	}
+
+	if err := ch.Qos(
+		c.prefetch, // prefetch count
+		0,          // prefetch size
+		false,      // global
+	); err != nil {
+		ch.Close()
+		return err
+	}
 
-	err = ch.ExchangeDeclare(
+	c.mu.Lock()
+	c.ch = ch
+	c.queue = queue
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Consumer) channel() *amqp091.Channel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ch
+}
+
+// declareTopology declares the exchange, the main queue with its binding,
+// and the retry and dead-letter queues. Returns the main queue name.
+//
+// The main queue is declared without arguments, as it always was, since
+// redeclaring an existing queue with different ones fails. Messages are moved
+// to the dead-letter queue by the consumer instead of by a queue argument.
+func declareTopology(ch *amqp091.Channel, cfg ConsumerConfig) (string, error) {
+	err := ch.ExchangeDeclare(
 		cfg.Exchange,
 		cfg.ExchangeType,
 		true,
@@ -35,7 +182,12 @@ func NewChannel(conn *amqp091.Connection, cfg ConsumerConfig) (*Consumer, error)
 		nil,
 	)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	// dead-letter queue for messages that ran out of attempts
+	if _, err := ch.QueueDeclare(deadLetterQueue(cfg.Queue), true, false, false, false, nil); err != nil {
+		return "", err
 	}
 
 	// create queue
@@ -48,7 +200,7 @@ func NewChannel(conn *amqp091.Connection, cfg ConsumerConfig) (*Consumer, error)
 		nil,       // Arguments
 	)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	// bind it
@@ -60,51 +212,358 @@ func NewChannel(conn *amqp091.Connection, cfg ConsumerConfig) (*Consumer, error)
 		nil,
 	)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return &Consumer{
-		ch:       ch,
-		exchange: cfg.Exchange,
-		queue:    q.Name,
-	}, nil
-}
+	// one delay queue per attempt, each expiring back into the main queue
+	if err := declareRetryQueues(ch, q.Name, cfg.MaxRetries, cfg.RetryBaseDelay); err != nil {
+		return "", err
+	}
+
+	if cfg.ControlRoutingKey != "" {
+		if err := declareControlQueue(ch, cfg); err != nil {
+			return "", fmt.Errorf("failed to declare control queue: %w", err)
+		}
+	}
 
-func (p *Consumer) Close() error {
-	return p.ch.Close()
+	return q.Name, nil
 }
 
-func (c *Consumer) Start(handler func(amqp091.Delivery)) error {
-	if err := c.ch.Qos(
-		1,     // prefetch count
-		0,     // prefetch size
-		false, // global
-	); err != nil {
+// declareControlQueue declares the durable queue control messages are routed
+// to. It is shared by every worker, whichever one reads a message acts on it.
+func declareControlQueue(ch *amqp091.Channel, cfg ConsumerConfig) error {
+	q, err := ch.QueueDeclare(controlQueue(cfg.Queue), true, false, false, false, nil)
+	if err != nil {
 		return err
 	}
+	return ch.QueueBind(q.Name, cfg.ControlRoutingKey, cfg.Exchange, false, nil)
+}
 
-	// 2. Start Consuming
-	msgs, err := c.ch.Consume(
-		c.queue, // Queue name
-		"",      // Consumer tag (empty = auto-generated)
-		true,    // Auto-Ack: TRUE (User requested auto-ack)
-		false,   // Exclusive
-		false,   // No-local
-		false,   // No-wait
-		nil,     // Args
-	)
+// declareRetryQueues declares the delay queue of every attempt. Each is named
+// after its delay, as a queue's TTL can't change once declared: another
+// RetryBaseDelay gets queues of its own, while messages already waiting in
+// the old ones still expire back into the main queue.
+func declareRetryQueues(ch *amqp091.Channel, queue string, maxRetries int, baseDelay time.Duration) error {
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		delay := retryDelay(baseDelay, attempt)
+		_, err := ch.QueueDeclare(
+			retryQueue(queue, delay),
+			true,
+			false,
+			false,
+			false,
+			amqp091.Table{
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    "", // default exchange routes by queue name
+				"x-dead-letter-routing-key": queue,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue %d: %w", attempt, err)
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) Close() error {
+	c.closing.Store(true)
+	c.cancelJobs()
+	c.republisher.Close()
+	return c.channel().Close()
+}
+
+// Shutdown stops taking new deliveries and waits for in-flight jobs to finish.
+// When ctx expires first, the remaining jobs are cancelled and their messages
+// requeued so another worker can pick them up.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.closing.Store(true)
+
+	if err := c.channel().Cancel(c.tag, false); err != nil {
+		log.Printf("Failed to cancel consumer: %v", err)
+	}
+	if c.cfg.ControlRoutingKey != "" {
+		if err := c.channel().Cancel(c.controlTag(), false); err != nil {
+			log.Printf("Failed to cancel control consumer: %v", err)
+		}
+	}
+
+	select {
+	case <-c.stopped:
+		log.Println("All in-flight jobs finished")
+	case <-ctx.Done():
+		log.Println("Drain deadline reached, cancelling in-flight jobs")
+		c.cancelJobs()
+		select {
+		case <-c.stopped:
+		case <-time.After(requeueGrace):
+			log.Println("Jobs still running, closing channel to requeue them")
+		}
+	}
+
+	c.cancelJobs()
+	c.republisher.Close()
+	return c.channel().Close()
+}
+
+// Start consumes the queue with a pool of workers. Deliveries that map to the
+// same key (see KeyFunc) are handled one after another by a single worker.
+// If the channel or connection drops, consuming resumes once it is back.
+func (c *Consumer) Start(handler Handler, key KeyFunc) error {
+	msgs, err := c.consume(handler, key)
 	if err != nil {
 		return err
 	}
 
+	keys := newKeyedQueue()
+	jobs := make(chan keyedDelivery)
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.work(jobs, keys, handler)
+		}()
+	}
+
 	go func() {
-		for d := range msgs {
-			// Pass the message to the handler function
-			// We process sequentially because prefetch count is 1
-			handler(d)
+		for msgs != nil {
+			for d := range msgs {
+				if c.closing.Load() {
+					// Shutting down, leave it for another worker
+					c.settle(d, Requeue)
+					continue
+				}
+
+				kd := keyedDelivery{d: d}
+				if key != nil {
+					kd.key = key(d)
+				}
+				if kd.key == KeyInline {
+					c.settle(d, handler(c.jobCtx, d))
+					continue
+				}
+				// Busy keys are parked and picked up by the worker that owns them
+				switch keys.claim(kd) {
+				case claimed:
+					jobs <- kd
+				case full:
+					time.AfterFunc(parkedRequeueDelay, func() { c.settle(d, Requeue) })
+				}
+			}
+
+			if c.closing.Load() {
+				break
+			}
+			log.Println("Consumer channel closed, re-establishing")
+			if n := keys.dropParked(); n > 0 {
+				log.Printf("Dropped %d parked deliveries of the closed channel, they will be redelivered", n)
+			}
+			msgs = c.resume(handler, key)
 		}
-		fmt.Println("Channel closed, stopping consumer")
+		close(jobs)
+		wg.Wait()
+		close(c.stopped)
+		log.Println("Consumer stopped")
 	}()
 
 	return nil
 }
+
+// consume starts consuming the main queue, and the control queue if there is
+// one, on the current channel. Control deliveries are handled right away on a
+// goroutine of their own, which ends when the channel closes.
+func (c *Consumer) consume(handler Handler, key KeyFunc) (<-chan amqp091.Delivery, error) {
+	c.mu.RLock()
+	ch, queue := c.ch, c.queue
+	c.mu.RUnlock()
+
+	msgs, err := ch.Consume(
+		queue, // Queue name
+		c.tag, // Consumer tag, needed to cancel it on shutdown
+		false, // Auto-Ack: messages are settled once the handler returns
+		false, // Exclusive
+		false, // No-local
+		false, // No-wait
+		nil,   // Args
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.cfg.ControlRoutingKey != "" {
+		// Qos is per consumer, so control messages get a prefetch window of their own
+		control, err := ch.Consume(controlQueue(c.cfg.Queue), c.controlTag(), false, false, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to consume control queue: %w", err)
+		}
+		go c.control(control, handler, key)
+	}
+	return msgs, nil
+}
+
+// control handles control deliveries one by one as they arrive
+func (c *Consumer) control(msgs <-chan amqp091.Delivery, handler Handler, key KeyFunc) {
+	for d := range msgs {
+		if key == nil || key(d) != KeyInline {
+			log.Printf("Rejecting message %d on the control queue, it is not a control message", d.DeliveryTag)
+			c.settle(d, Reject)
+			continue
+		}
+		c.settle(d, handler(c.jobCtx, d))
+	}
+}
+
+func (c *Consumer) controlTag() string {
+	return c.tag + "-control"
+}
+
+// resume re-opens the channel and starts consuming again, backing off between
+// failed attempts. Returns nil once the consumer or the client is closed.
+func (c *Consumer) resume(handler Handler, key KeyFunc) <-chan amqp091.Delivery {
+	delay := minReconnectDelay
+	for !c.closing.Load() {
+		err := c.open(context.Background())
+		if err == nil {
+			var msgs <-chan amqp091.Delivery
+			if msgs, err = c.consume(handler, key); err == nil {
+				log.Println("Consumer resumed")
+				return msgs
+			}
+		}
+		if errors.Is(err, ErrClientClosed) {
+			return nil
+		}
+
+		log.Printf("Failed to resume consumer, retrying in %s: %v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+	return nil
+}
+
+// work handles deliveries from the pool, draining any deliveries parked
+// behind the same key before taking new work
+func (c *Consumer) work(jobs <-chan keyedDelivery, keys *keyedQueue, handler Handler) {
+	for kd := range jobs {
+		for d, ok := kd.d, true; ok; d, ok = keys.next(kd.key) {
+			if c.closing.Load() {
+				// Shutdown has begun, hand it back instead of starting it
+				c.settle(d, Requeue)
+				continue
+			}
+			c.settle(d, handler(c.jobCtx, d))
+		}
+	}
+}
+
+// settle acks, retries or dead-letters a delivery based on the handler result
+func (c *Consumer) settle(d amqp091.Delivery, result Result) {
+	var err error
+
+	switch result {
+	case Ack:
+		err = d.Ack(false)
+	case Reject:
+		err = c.deadLetter(d)
+	case Requeue:
+		err = d.Nack(false, true)
+	case Retry:
+		err = c.retry(d)
+	}
+
+	if err != nil {
+		log.Printf("Failed to settle message %d: %v", d.DeliveryTag, err)
+	}
+}
+
+// retry republishes the message to the delay queue for its next attempt,
+// or dead-letters it once all attempts are used up
+func (c *Consumer) retry(d amqp091.Delivery) error {
+	attempt := RetryCount(d) + 1
+	if attempt > c.maxRetries {
+		log.Printf("Message %d exhausted %d retries, dead-lettering", d.DeliveryTag, c.maxRetries)
+		return c.deadLetter(d)
+	}
+
+	c.mu.RLock()
+	queue := c.queue
+	c.mu.RUnlock()
+
+	if err := c.republish(d, retryQueue(queue, retryDelay(c.cfg.RetryBaseDelay, attempt)), attempt); err != nil {
+		// Could not schedule the retry, put it back on the queue instead of losing it
+		log.Printf("Failed to schedule retry for message %d: %v", d.DeliveryTag, err)
+		return d.Nack(false, true)
+	}
+
+	log.Printf("Scheduled retry %d/%d for message %d", attempt, c.maxRetries, d.DeliveryTag)
+	return d.Ack(false)
+}
+
+// deadLetter moves the message to the dead-letter queue
+func (c *Consumer) deadLetter(d amqp091.Delivery) error {
+	if err := c.republish(d, deadLetterQueue(c.cfg.Queue), RetryCount(d)); err != nil {
+		log.Printf("Failed to dead-letter message %d: %v", d.DeliveryTag, err)
+		return d.Nack(false, true)
+	}
+	return d.Ack(false)
+}
+
+// republish copies the delivery onto queue with its retry count set to retries,
+// returning once the broker has confirmed the copy
+func (c *Consumer) republish(d amqp091.Delivery, queue string, retries int) error {
+	headers := amqp091.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[RetryCountHeader] = int32(retries)
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	return c.republisher.PublishMessage(ctx, queue, amqp091.Publishing{
+		Headers:       headers,
+		ContentType:   d.ContentType,
+		DeliveryMode:  amqp091.Persistent,
+		CorrelationId: d.CorrelationId,
+		MessageId:     d.MessageId,
+		Timestamp:     d.Timestamp,
+		Type:          d.Type,
+		Body:          d.Body,
+	})
+}
+
+// RetryCount returns how many times the delivery has already been retried
+func RetryCount(d amqp091.Delivery) int {
+	switch v := d.Headers[RetryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// retryDelay doubles the base delay for every attempt after the first
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	return base << (attempt - 1)
+}
+
+// retryQueue names the delay queue whose messages wait delay before going back to queue
+func retryQueue(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%dms", queue, delay.Milliseconds())
+}
+
+func controlQueue(queue string) string {
+	return queue + ".control"
+}
+
+func deadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
@@ -0,0 +1,54 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers amqp091.Table
+		want    int
+	}{
+		{name: "no headers", headers: nil, want: 0},
+		{name: "int32 as published", headers: amqp091.Table{RetryCountHeader: int32(2)}, want: 2},
+		{name: "int64", headers: amqp091.Table{RetryCountHeader: int64(3)}, want: 3},
+		{name: "int", headers: amqp091.Table{RetryCountHeader: 4}, want: 4},
+		{name: "unexpected type", headers: amqp091.Table{RetryCountHeader: "5"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetryCount(amqp091.Delivery{Headers: tt.headers}); got != tt.want {
+				t.Errorf("RetryCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	base := 5 * time.Second
+	for attempt, want := range map[int]time.Duration{
+		1: 5 * time.Second,
+		2: 10 * time.Second,
+		3: 20 * time.Second,
+		5: 80 * time.Second,
+	} {
+		if got := retryDelay(base, attempt); got != want {
+			t.Errorf("retryDelay(%s, %d) = %s, want %s", base, attempt, got, want)
+		}
+	}
+}
+
+func TestRetryQueue(t *testing.T) {
+	if got, want := retryQueue("jobs", 5*time.Second), "jobs.retry.5000ms"; got != want {
+		t.Errorf("retryQueue() = %q, want %q", got, want)
+	}
+	// Another base delay must never reuse a queue declared with a different TTL
+	if retryQueue("jobs", retryDelay(5*time.Second, 2)) == retryQueue("jobs", retryDelay(4*time.Second, 2)) {
+		t.Error("retryQueue() gives the same name for different delays")
+	}
+}
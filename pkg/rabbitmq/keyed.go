@@ -0,0 +1,108 @@
+package rabbitmq
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// KeyFunc extracts the key deliveries are serialized on. Deliveries that share
+// a key are never handled concurrently; an empty key means no ordering is needed.
+type KeyFunc func(amqp091.Delivery) string
+
+// KeyInline is returned by a KeyFunc for control deliveries that must not wait
+// behind running jobs. They are handled right away on the dispatching
+// goroutine, so their handler has to return quickly.
+const KeyInline = "\x00inline"
+
+const (
+	// maxParkedPerKey bounds the deliveries waiting behind a busy key. Parked
+	// deliveries hold prefetch slots, so without a bound one busy source could
+	// take them all while the other workers sit idle.
+	maxParkedPerKey = 4
+	// parkedRequeueDelay is how long a delivery past the bound is held before
+	// it is requeued, so the broker doesn't hand it straight back
+	parkedRequeueDelay = time.Second
+)
+
+// claimResult says what became of a delivery handed to keyedQueue.claim
+type claimResult int
+
+const (
+	claimed claimResult = iota // the caller owns the key and runs the delivery
+	parked                     // waiting for the worker that owns the key
+	full                       // the key has maxParkedPerKey deliveries waiting, requeue it
+)
+
+type keyedDelivery struct {
+	key string
+	d   amqp091.Delivery
+}
+
+// keyedQueue tracks which keys are being worked on and parks deliveries for
+// a busy key until the worker that owns it is done
+type keyedQueue struct {
+	mu      sync.Mutex
+	pending map[string][]amqp091.Delivery // key present = owned by a worker
+}
+
+func newKeyedQueue() *keyedQueue {
+	return &keyedQueue{pending: make(map[string][]amqp091.Delivery)}
+}
+
+// claim marks the key as busy. If another worker owns the key the delivery is
+// parked and will be handed to that worker, unless too many already wait.
+func (q *keyedQueue) claim(kd keyedDelivery) claimResult {
+	if kd.key == "" {
+		return claimed
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if waiting, busy := q.pending[kd.key]; busy {
+		if len(waiting) >= maxParkedPerKey {
+			return full
+		}
+		q.pending[kd.key] = append(waiting, kd.d)
+		return parked
+	}
+	q.pending[kd.key] = nil
+	return claimed
+}
+
+// dropParked forgets every parked delivery, leaving their keys owned by the
+// workers running them. Used when the channel the deliveries came on closed:
+// they can't be settled any more and the broker delivers them again.
+// Returns how many were dropped.
+func (q *keyedQueue) dropParked() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dropped := 0
+	for key, parked := range q.pending {
+		dropped += len(parked)
+		q.pending[key] = nil
+	}
+	return dropped
+}
+
+// next returns the next parked delivery for the key, or releases the key
+// when there is nothing left
+func (q *keyedQueue) next(key string) (amqp091.Delivery, bool) {
+	if key == "" {
+		return amqp091.Delivery{}, false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	parked := q.pending[key]
+	if len(parked) == 0 {
+		delete(q.pending, key)
+		return amqp091.Delivery{}, false
+	}
+	q.pending[key] = parked[1:]
+	return parked[0], true
+}
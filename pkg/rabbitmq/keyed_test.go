@@ -0,0 +1,87 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func delivery(key string, tag uint64) keyedDelivery {
+	return keyedDelivery{key: key, d: amqp091.Delivery{DeliveryTag: tag}}
+}
+
+func TestKeyedQueueClaim(t *testing.T) {
+	q := newKeyedQueue()
+
+	if got := q.claim(delivery("a", 1)); got != claimed {
+		t.Fatalf("claim() of a free key = %v, want claimed", got)
+	}
+	if got := q.claim(delivery("b", 2)); got != claimed {
+		t.Errorf("claim() of another free key = %v, want claimed", got)
+	}
+	for tag := uint64(3); tag < 3+maxParkedPerKey; tag++ {
+		if got := q.claim(delivery("a", tag)); got != parked {
+			t.Errorf("claim() %d of a busy key = %v, want parked", tag, got)
+		}
+	}
+	if got := q.claim(delivery("a", 99)); got != full {
+		t.Errorf("claim() past maxParkedPerKey = %v, want full", got)
+	}
+}
+
+func TestKeyedQueueEmptyKey(t *testing.T) {
+	q := newKeyedQueue()
+
+	for i := 0; i < 3; i++ {
+		if got := q.claim(delivery("", uint64(i))); got != claimed {
+			t.Errorf("claim() without a key = %v, want claimed", got)
+		}
+	}
+	if _, ok := q.next(""); ok {
+		t.Error("next() without a key returned a delivery")
+	}
+}
+
+func TestKeyedQueueNext(t *testing.T) {
+	q := newKeyedQueue()
+	q.claim(delivery("a", 1))
+	q.claim(delivery("a", 2))
+	q.claim(delivery("a", 3))
+
+	// Parked deliveries come back in order, then the key is released
+	for _, want := range []uint64{2, 3} {
+		d, ok := q.next("a")
+		if !ok || d.DeliveryTag != want {
+			t.Fatalf("next() = %d, %v, want %d, true", d.DeliveryTag, ok, want)
+		}
+	}
+	if _, ok := q.next("a"); ok {
+		t.Fatal("next() returned a delivery after the parked ones")
+	}
+	if got := q.claim(delivery("a", 4)); got != claimed {
+		t.Errorf("claim() after the key was released = %v, want claimed", got)
+	}
+}
+
+func TestKeyedQueueDropParked(t *testing.T) {
+	q := newKeyedQueue()
+	q.claim(delivery("a", 1))
+	q.claim(delivery("a", 2))
+	q.claim(delivery("a", 3))
+	q.claim(delivery("b", 4))
+
+	if got := q.dropParked(); got != 2 {
+		t.Errorf("dropParked() = %d, want 2", got)
+	}
+
+	// The key stays owned by the worker still running delivery 1
+	if got := q.claim(delivery("a", 5)); got != parked {
+		t.Errorf("claim() after dropParked() = %v, want parked", got)
+	}
+	if d, ok := q.next("a"); !ok || d.DeliveryTag != 5 {
+		t.Errorf("next() = %d, %v, want the delivery parked after the drop", d.DeliveryTag, ok)
+	}
+	if _, ok := q.next("a"); ok {
+		t.Error("next() returned a dropped delivery")
+	}
+}
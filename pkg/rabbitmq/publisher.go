@@ -0,0 +1,124 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+type PublisherConfig struct {
+	// Exchange is left empty to publish straight to queues through the default exchange
+	Exchange     string
+	ExchangeType string
+}
+
+// Publisher publishes persistent messages to an exchange with publisher
+// confirms, re-opening its channel after the connection recovers
+type Publisher struct {
+	client *RabbitClient
+	cfg    PublisherConfig
+
+	mu sync.Mutex
+	ch *amqp091.Channel
+}
+
+func NewPublisher(client *RabbitClient, cfg PublisherConfig) (*Publisher, error) {
+	p := &Publisher{
+		client: client,
+		cfg:    cfg,
+	}
+
+	if err := p.open(context.Background()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// open creates a channel in confirm mode and declares the exchange on it
+func (p *Publisher) open(ctx context.Context) error {
+	ch, err := p.client.Channel(ctx)
+	if err != nil {
+		return err
+	}
+
+	if p.cfg.Exchange != "" {
+		err = ch.ExchangeDeclare(
+			p.cfg.Exchange,
+			p.cfg.ExchangeType,
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+		if err != nil {
+			ch.Close()
+			return err
+		}
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	p.ch = ch
+	return nil
+}
+
+// Publish sends body with the given routing key and waits until the broker confirms it
+func (p *Publisher) Publish(ctx context.Context, routingKey string, body []byte) error {
+	return p.PublishMessage(ctx, routingKey, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		Timestamp:    time.Now(),
+		Body:         body,
+	})
+}
+
+// PublishMessage sends msg as is with the given routing key and waits until
+// the broker confirms it, e.g. to pass on a delivery with its headers
+func (p *Publisher) PublishMessage(ctx context.Context, routingKey string, msg amqp091.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch == nil || p.ch.IsClosed() {
+		if err := p.open(ctx); err != nil {
+			return fmt.Errorf("failed to open publisher channel: %w", err)
+		}
+	}
+
+	confirm, err := p.ch.PublishWithDeferredConfirmWithContext(ctx,
+		p.cfg.Exchange,
+		routingKey,
+		false, // Mandatory
+		false, // Immediate
+		msg,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", routingKey, err)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed waiting for confirm on %s: %w", routingKey, err)
+	}
+	if !acked {
+		return fmt.Errorf("broker rejected message on %s", routingKey)
+	}
+
+	return nil
+}
+
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch != nil && !p.ch.IsClosed() {
+		return p.ch.Close()
+	}
+	return nil
+}
@@ -8,10 +8,33 @@ import (
 type Chunk struct {
 	Text  string
 	Index int
+
+	// Context is prepended to Text when embedding, e.g. the heading path
+	Context string
+	// Metadata is stored on the chunk's vector next to the source's metadata
+	Metadata map[string]interface{}
+	// Parent is the index of the parent section the chunk was split from, see SplitParents
+	Parent int
+	// Start and End are the character offsets of Text in the source text, see Locate
+	Start int
+	End   int
+}
+
+// Located reports whether the chunk's offsets are known
+func (c Chunk) Located() bool {
+	return c.End > c.Start
+}
+
+// EmbedText is the text the chunk is embedded as: its context, if any, followed by its text
+func (c Chunk) EmbedText() string {
+	if c.Context == "" {
+		return c.Text
+	}
+	return c.Context + "\n\n" + c.Text
 }
 
 // SplitText splits a long string into chunks with overlap
-// chunkSize: strict character limit (approx tokens)
+// chunkSize: strict character limit (see Tokenizer for token-based sizing)
 // overlap: how many characters to repeat
 func SplitText(text string, chunkSize int, overlap int) []Chunk {
 	if chunkSize <= 0 {
@@ -0,0 +1,260 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Row formats accepted by CSVSplitter
+const (
+	CSVFormatTable    = "table"
+	CSVFormatKeyValue = "kv"
+)
+
+// csvDelimiters are tried in order, the first wins a tie
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// csvSniffRows is how many records delimiter detection looks at, within the
+// first csvSniffBytes of the file
+const (
+	csvSniffRows  = 20
+	csvSniffBytes = 64 * 1024
+)
+
+// CSVSplitter groups the rows of a CSV file into chunks of at most Size
+// tokens, each repeating the column headers, so no row is cut in half and
+// every chunk can be read on its own. The delimiter and the header row are
+// detected, and files without a header get column_1, column_2... names.
+//
+// Rows are rendered as a markdown table, or with Format "kv" as one line of
+// column=value pairs per row. Every chunk records the 1-based data rows it
+// holds in its row_start and row_end metadata. Split falls back to the
+// recursive splitter for text that doesn't parse as CSV. SplitReader reads
+// the file a row at a time, so it can handle files of any size.
+type CSVSplitter struct {
+	Size      int
+	Format    string
+	Tokenizer Tokenizer
+}
+
+func (s *CSVSplitter) Split(text string) []Chunk {
+	var chunks []Chunk
+	for chunk, err := range s.SplitReader(strings.NewReader(text)) {
+		if err != nil {
+			chunks = nil
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if len(chunks) == 0 {
+		return (&RecursiveSplitter{Size: s.Size, Tokenizer: s.Tokenizer}).Split(text)
+	}
+	return chunks
+}
+
+func (s *CSVSplitter) SplitReader(r io.Reader) iter.Seq2[Chunk, error] {
+	tok := tokenizerOr(s.Tokenizer)
+	size := s.Size
+	if size <= 0 {
+		size = 250
+	}
+
+	return func(yield func(Chunk, error) bool) {
+		br := bufio.NewReaderSize(r, csvSniffBytes)
+		if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\ufeff")) {
+			_, _ = br.Discard(3)
+		}
+		sample, _ := br.Peek(csvSniffBytes)
+
+		cr := csv.NewReader(br)
+		cr.Comma = sniffDelimiter(string(sample))
+		cr.LazyQuotes = true
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+
+		first, err := cr.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			yield(Chunk{}, err)
+			return
+		}
+
+		// The first record is data when it doesn't look like a header
+		var header []string
+		var pending [][]string
+		if isCSVHeader(first) {
+			for _, name := range first {
+				header = append(header, strings.TrimSpace(name))
+			}
+		} else {
+			for i := range first {
+				header = append(header, fmt.Sprintf("column_%d", i+1))
+			}
+			pending = [][]string{first}
+		}
+
+		render := renderTableRow
+		if s.Format == CSVFormatKeyValue {
+			render = renderKeyValueRow
+		}
+		head := func() string {
+			if s.Format == CSVFormatKeyValue {
+				return ""
+			}
+			return renderTableHeader(header)
+		}
+
+		var body strings.Builder
+		index, start, rows := 0, 0, 0
+		tokens := tok.Count(head())
+
+		flush := func() bool {
+			chunk := Chunk{
+				Text:  head() + body.String(),
+				Index: index,
+				Metadata: map[string]interface{}{
+					"row_start": start + 1,
+					"row_end":   rows,
+				},
+			}
+			index++
+			body.Reset()
+			start = rows
+			tokens = tok.Count(head())
+			return yield(chunk, nil)
+		}
+
+		for {
+			var row []string
+			if len(pending) > 0 {
+				row, pending = pending[0], pending[1:]
+			} else {
+				row, err = cr.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					yield(Chunk{}, err)
+					return
+				}
+			}
+
+			// Name any columns past the header, and pad short rows
+			for len(header) < len(row) {
+				header = append(header, fmt.Sprintf("column_%d", len(header)+1))
+			}
+			if len(row) < len(header) {
+				row = append(row, make([]string, len(header)-len(row))...)
+			}
+
+			line := render(header, row) + "\n"
+			lineTokens := tok.Count(line)
+			if rows > start && tokens+lineTokens > size {
+				if !flush() {
+					return
+				}
+			}
+			body.WriteString(line)
+			tokens += lineTokens
+			rows++
+		}
+
+		if rows > start {
+			flush()
+		}
+	}
+}
+
+// sniffDelimiter picks the delimiter that splits the first records into the
+// most consistent number of fields, preferring more fields on a tie
+func sniffDelimiter(text string) rune {
+	best, bestScore, bestFields := ',', 0.0, 0
+	for _, d := range csvDelimiters {
+		r := csv.NewReader(strings.NewReader(text))
+		r.Comma = d
+		r.LazyQuotes = true
+		r.FieldsPerRecord = -1
+
+		var counts []int
+		for len(counts) < csvSniffRows {
+			record, err := r.Read()
+			if err != nil {
+				break
+			}
+			counts = append(counts, len(record))
+		}
+		if len(counts) == 0 || counts[0] < 2 {
+			continue
+		}
+
+		same := 0
+		for _, n := range counts {
+			if n == counts[0] {
+				same++
+			}
+		}
+		score := float64(same) / float64(len(counts))
+		if score > bestScore || (score == bestScore && counts[0] > bestFields) {
+			best, bestScore, bestFields = d, score, counts[0]
+		}
+	}
+	return best
+}
+
+func isCSVHeader(record []string) bool {
+	seen := make(map[string]bool, len(record))
+	for _, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if cell == "" || seen[cell] || isNumeric(cell) {
+			return false
+		}
+		seen[cell] = true
+	}
+	return true
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	return err == nil
+}
+
+func renderTableHeader(header []string) string {
+	sep := make([]string, len(header))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	return renderTableRow(nil, header) + "\n" + renderTableRow(nil, sep) + "\n"
+}
+
+func renderTableRow(_ []string, row []string) string {
+	cells := make([]string, len(row))
+	for i, cell := range row {
+		cells[i] = csvCell(cell)
+	}
+	return "| " + strings.Join(cells, " | ") + " |"
+}
+
+func renderKeyValueRow(header []string, row []string) string {
+	pairs := make([]string, 0, len(row))
+	for i, cell := range row {
+		if cell = csvCell(cell); cell != "" {
+			pairs = append(pairs, header[i]+"="+cell)
+		}
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// csvCell flattens a cell onto one line and escapes pipes so it can't break the table
+func csvCell(cell string) string {
+	cell = strings.Join(strings.Fields(cell), " ")
+	return strings.ReplaceAll(cell, "|", `\|`)
+}
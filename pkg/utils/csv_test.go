@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCSVSplitter(t *testing.T) {
+	text := "name;age\nann;30\nbob;40\ncid;50\n"
+
+	tests := []struct {
+		name      string
+		format    string
+		size      int
+		want      []string
+		wantStart []int
+		wantEnd   []int
+	}{
+		{
+			name:   "table rows grouped under the header",
+			format: CSVFormatTable,
+			size:   20,
+			want: []string{
+				"| name | age |\n| --- | --- |\n| ann | 30 |\n| bob | 40 |\n",
+				"| name | age |\n| --- | --- |\n| cid | 50 |\n",
+			},
+			wantStart: []int{1, 3},
+			wantEnd:   []int{2, 3},
+		},
+		{
+			name:      "key value rows",
+			format:    CSVFormatKeyValue,
+			size:      2,
+			want:      []string{"name=ann; age=30\n", "name=bob; age=40\n", "name=cid; age=50\n"},
+			wantStart: []int{1, 2, 3},
+			wantEnd:   []int{1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &CSVSplitter{Size: tt.size, Format: tt.format, Tokenizer: wordTokenizer{}}
+			chunks := s.Split(text)
+			if got := texts(chunks); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Split() = %q, want %q", got, tt.want)
+			}
+			for i, c := range chunks {
+				if c.Metadata["row_start"] != tt.wantStart[i] || c.Metadata["row_end"] != tt.wantEnd[i] {
+					t.Errorf("chunk %d rows = %v-%v, want %d-%d", i, c.Metadata["row_start"], c.Metadata["row_end"], tt.wantStart[i], tt.wantEnd[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCSVSplitterWithoutHeader(t *testing.T) {
+	chunks := (&CSVSplitter{Size: 100, Format: CSVFormatKeyValue}).Split("1,2\n3,4\n")
+	want := []string{"column_1=1; column_2=2\ncolumn_1=3; column_2=4\n"}
+	if got := texts(chunks); !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %q, want %q", got, want)
+	}
+}
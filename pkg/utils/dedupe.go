@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingleSize is the number of consecutive words hashed together by SimHash
+const shingleSize = 3
+
+// SimHash fingerprints text so that similar texts get fingerprints a small
+// Hamming distance apart. Words are lowercased and stripped of punctuation,
+// and hashed as overlapping shingles of shingleSize words. Numbers are words
+// too, so tables that differ only in their figures are not alike.
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	add := func(shingle []string) {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(shingle, " ")))
+		sum := h.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	if len(words) < shingleSize {
+		add(words)
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		add(words[i : i+shingleSize])
+	}
+
+	var fingerprint uint64
+	for bit, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// NearDuplicate reports whether two simhashes differ in at most maxDistance bits
+func NearDuplicate(a, b uint64, maxDistance int) bool {
+	return bits.OnesCount64(a^b) <= maxDistance
+}
+
+// Deduper spots exact and near duplicates (simhashes at most maxDistance bits
+// apart) in a stream of chunk texts, remembering every text it has let through.
+// seen holds simhashes of texts already indexed elsewhere, which also count.
+type Deduper struct {
+	maxDistance int
+	seen        []uint64
+}
+
+func NewDeduper(maxDistance int, seen []uint64) *Deduper {
+	return &Deduper{maxDistance: maxDistance, seen: seen}
+}
+
+// Add returns the text's simhash and whether it duplicates an earlier text,
+// remembering it if not. Texts without words hash to 0 and are never duplicates.
+func (d *Deduper) Add(text string) (uint64, bool) {
+	hash := SimHash(text)
+	if hash == 0 {
+		return 0, false
+	}
+
+	for _, h := range d.seen {
+		if NearDuplicate(hash, h, d.maxDistance) {
+			return hash, true
+		}
+	}
+	d.seen = append(d.seen, hash)
+	return hash, false
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+const prose = `The quarterly report shows revenue grew across every region, led by strong
+demand in the north where two new stores opened in spring. Costs rose more slowly
+than sales, so the margin improved for the third quarter in a row. The board
+expects the trend to continue as the new warehouse comes online next year.`
+
+func TestSimHashDistance(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		maxDist int // the two must be at most this many bits apart
+		minDist int // and at least this many
+	}{
+		{
+			name:    "identical",
+			a:       prose,
+			b:       prose,
+			maxDist: 0,
+		},
+		{
+			name:    "case, punctuation and spacing are ignored",
+			a:       "Page footer: Acme Corp, all rights reserved.",
+			b:       "page footer   acme corp all rights reserved",
+			maxDist: 0,
+		},
+		{
+			name:    "unrelated text",
+			a:       prose,
+			b:       "Install the package with the command line tool, then restart the service and check its logs for errors before going on.",
+			minDist: 4,
+			maxDist: 64,
+		},
+		{
+			name:    "numbers are words",
+			a:       "| 2021 | 100 | 200 | 300 |\n| 2022 | 110 | 210 | 310 |",
+			b:       "| 2023 | 400 | 500 | 600 |\n| 2024 | 410 | 510 | 610 |",
+			minDist: 4,
+			maxDist: 64,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := bits.OnesCount64(SimHash(tt.a) ^ SimHash(tt.b))
+			if d < tt.minDist || d > tt.maxDist {
+				t.Errorf("distance = %d, want %d to %d", d, tt.minDist, tt.maxDist)
+			}
+			if got, want := NearDuplicate(SimHash(tt.a), SimHash(tt.b), 3), d <= 3; got != want {
+				t.Errorf("NearDuplicate(3) = %v at distance %d", got, d)
+			}
+		})
+	}
+}
+
+func TestSimHashSmallEditsStayClose(t *testing.T) {
+	edited := bits.OnesCount64(SimHash(prose) ^ SimHash(strings.Replace(prose, "spring", "summer", 1)))
+	unrelated := bits.OnesCount64(SimHash(prose) ^ SimHash("Install the package with the command line tool, then restart the service."))
+	if edited >= unrelated {
+		t.Errorf("one word changed is %d bits away, unrelated text %d", edited, unrelated)
+	}
+}
+
+func TestSimHashWithoutWords(t *testing.T) {
+	for _, text := range []string{"", "  \n", "--- | --- | ---", "!!!"} {
+		if h := SimHash(text); h != 0 {
+			t.Errorf("SimHash(%q) = %x, want 0", text, h)
+		}
+	}
+}
+
+func TestDeduper(t *testing.T) {
+	d := NewDeduper(3, nil)
+
+	steps := []struct {
+		text string
+		dup  bool
+	}{
+		{prose, false},
+		{strings.ToUpper(prose), true},
+		{strings.ReplaceAll(prose, ",", "") + "\n\n", true},
+		{"Something else entirely, about a cat that sat on a mat all afternoon long.", false},
+		{"--- | ---", false},
+		{"--- | ---", false}, // no words, never a duplicate
+	}
+	for i, s := range steps {
+		if _, got := d.Add(s.text); got != s.dup {
+			t.Errorf("step %d: Add() = %v, want %v", i, got, s.dup)
+		}
+	}
+}
+
+func TestDeduperSeen(t *testing.T) {
+	d := NewDeduper(3, []uint64{SimHash(prose)})
+
+	hash, dup := d.Add(prose)
+	if !dup {
+		t.Error("Add() kept a text whose simhash was already seen")
+	}
+	if hash != SimHash(prose) {
+		t.Errorf("Add() = %x, want the text's simhash %x", hash, SimHash(prose))
+	}
+	if hash, dup := d.Add("--- | ---"); hash != 0 || dup {
+		t.Errorf("Add() of a text without words = %x, %v, want 0, false", hash, dup)
+	}
+}
+
+// Chunks of a numeric table share their header and layout but differ in every
+// figure, none of them may be dropped as a duplicate of another
+func TestDeduperKeepsNumericTableChunks(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("| id | region | units | revenue |\n| --- | --- | --- | --- |\n")
+	for i := 1; i <= 200; i++ {
+		fmt.Fprintf(&b, "| %d | %d | %d | %d.%02d |\n", i, i%7, i*13%997, i*37%1009, i%100)
+	}
+
+	chunks := (&MarkdownSplitter{Size: 250}).Split(b.String())
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want the table split", len(chunks))
+	}
+
+	d := NewDeduper(3, nil)
+	for _, c := range chunks {
+		if _, dup := d.Add(c.Text); dup {
+			t.Errorf("chunk %d dropped as a duplicate: %.80q", c.Index, c.Text)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ContentHash returns the hex encoded SHA-256 of the normalized text, so
+// changes in line endings or spacing alone don't count as new content
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(NormalizeText(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeText collapses every run of spaces within a line, unifies line
+// endings and drops blank lines
+func NormalizeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// ContentHashReader is ContentHash for text read from r, normalizing it as it
+// streams by instead of holding it in memory. Both give the same hash.
+func ContentHashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	w := bufio.NewWriter(h)
+	br := bufio.NewReader(r)
+
+	started, inLine, space := false, false, false
+	for {
+		c, size, err := br.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case c == '\n' || c == '\r':
+			inLine, space = false, false
+		case unicode.IsSpace(c):
+			space = inLine
+		default:
+			if !inLine {
+				if started {
+					w.WriteByte('\n')
+				}
+				started, inLine = true, true
+			} else if space {
+				w.WriteByte(' ')
+			}
+			space = false
+
+			if c == utf8.RuneError && size == 1 {
+				// Hash invalid bytes as they are, like ContentHash does
+				_ = br.UnreadRune()
+				b, _ := br.ReadByte()
+				w.WriteByte(b)
+			} else {
+				w.WriteRune(c)
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContentHashIgnoresSpacing(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"line endings", "a\nb\n", "a\r\nb\r\n"},
+		{"old mac line endings", "a\nb", "a\rb"},
+		{"runs of spaces", "a b", "a  \t b"},
+		{"leading and trailing spaces", "a b", "  a b  "},
+		{"blank lines", "a\nb", "\n\na\n\n \n\nb\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ContentHash(tt.a) != ContentHash(tt.b) {
+				t.Errorf("ContentHash(%q) != ContentHash(%q)", tt.a, tt.b)
+			}
+		})
+	}
+
+	if ContentHash("a b") == ContentHash("a\nb") {
+		t.Error("a line break hashes like a space")
+	}
+	if ContentHash("ab") == ContentHash("a b") {
+		t.Error("a space hashes like nothing")
+	}
+}
+
+func TestContentHashReaderMatchesContentHash(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"empty", ""},
+		{"only whitespace", " \t\r\n\n "},
+		{"one word", "a"},
+		{"mixed spacing", "  a  b\r\nc\r\n\r\n d \n"},
+		{"carriage returns", "a\rb\r\rc"},
+		{"unicode", "héllo\twörld\n日本語 テキスト"},
+		{"unicode spaces", "a  b c"},
+		{"vertical tab and form feed", "a\vb\fc"},
+		{"invalid utf-8", "\xff\xfe abc \xc3"},
+		{"long", strings.Repeat("line   with  spaces\r\n\n", 10000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ContentHashReader(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := ContentHash(tt.text); got != want {
+				t.Errorf("ContentHashReader(%q) = %s, ContentHash = %s", tt.text, got, want)
+			}
+		})
+	}
+}
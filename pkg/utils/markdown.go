@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// HeadingSeparator joins the headings of a chunk's heading path
+const HeadingSeparator = " > "
+
+var (
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listItemPattern = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+`)
+)
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockCode
+	blockTable
+	blockList
+)
+
+type mdBlock struct {
+	kind  blockKind
+	text  string
+	level int // heading level
+}
+
+// MarkdownSplitter splits markdown on its headings and packs the blocks of
+// each section (paragraphs, lists, tables, fenced code) into chunks of at most
+// Size tokens without breaking a block apart unless it is larger than Size by
+// itself. Every chunk carries its heading path, e.g. "Chapter 2 > Pricing", as
+// embedding context and as heading_path metadata.
+type MarkdownSplitter struct {
+	Size      int
+	Overlap   int
+	Tokenizer Tokenizer
+}
+
+func (s *MarkdownSplitter) Split(text string) []Chunk {
+	chunks, _ := s.splitUnder(nil, text)
+	return chunks
+}
+
+// splitUnder splits text that follows the headings of path, returning the
+// chunks and the heading path at the end of text
+func (s *MarkdownSplitter) splitUnder(path []string, text string) ([]Chunk, []string) {
+	size := s.Size
+	if size <= 0 {
+		size = 250
+	}
+	tok := tokenizerOr(s.Tokenizer)
+	// Oversized blocks fall back to the recursive splitter
+	fallback := &RecursiveSplitter{Size: size, Overlap: s.Overlap, Tokenizer: tok}
+
+	var chunks []Chunk
+	path = append([]string(nil), path...) // heading text per level, path[i] is level i+1
+	var section []string
+	length := 0
+
+	emit := func(body string) {
+		body = strings.TrimSpace(body)
+		if body == "" {
+			return
+		}
+		chunk := Chunk{Text: body, Index: len(chunks)}
+		if breadcrumb := headingPath(path); breadcrumb != "" {
+			chunk.Context = breadcrumb
+			chunk.Metadata = map[string]interface{}{"heading_path": breadcrumb}
+		}
+		chunks = append(chunks, chunk)
+	}
+	flush := func() {
+		if len(section) > 0 {
+			emit(strings.Join(section, "\n\n"))
+		}
+		section, length = nil, 0
+	}
+
+	for _, b := range parseMarkdown(text) {
+		if b.kind == blockHeading {
+			flush()
+			if len(path) >= b.level {
+				path = path[:b.level-1]
+			}
+			for len(path) < b.level-1 {
+				path = append(path, "")
+			}
+			path = append(path, b.text)
+			continue
+		}
+
+		n := tok.Count(b.text)
+		if n > size {
+			// Too big to keep whole, split it on its own
+			flush()
+			for _, part := range splitBlock(b, size, fallback, tok) {
+				emit(part)
+			}
+			continue
+		}
+
+		if len(section) > 0 && length+n > size {
+			flush()
+		}
+		section = append(section, b.text)
+		length += n
+	}
+	flush()
+
+	return chunks, path
+}
+
+// headingPath joins the non-empty headings of path
+func headingPath(path []string) string {
+	var parts []string
+	for _, h := range path {
+		if h != "" {
+			parts = append(parts, h)
+		}
+	}
+	return strings.Join(parts, HeadingSeparator)
+}
+
+// splitBlock breaks a block larger than size. Tables are cut between rows with
+// the header repeated on every part, everything else goes through fallback.
+func splitBlock(b mdBlock, size int, fallback Splitter, tok Tokenizer) []string {
+	if b.kind == blockTable {
+		lines := strings.Split(b.text, "\n")
+		header := strings.Join(lines[:min(2, len(lines))], "\n")
+		if rowBudget := size - tok.Count(header); len(lines) > 2 && rowBudget > 0 {
+			var parts []string
+			for _, rows := range mergePieces(withNewlines(lines[2:]), rowBudget, 0, tok) {
+				parts = append(parts, header+"\n"+strings.TrimRight(rows, "\n"))
+			}
+			return parts
+		}
+	}
+
+	var parts []string
+	for _, c := range fallback.Split(b.text) {
+		parts = append(parts, c.Text)
+	}
+	return parts
+}
+
+func withNewlines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = l + "\n"
+	}
+	return out
+}
+
+// parseMarkdown groups lines into headings, fenced code blocks, tables, lists
+// and paragraphs. Blank lines end every block except fenced code.
+func parseMarkdown(text string) []mdBlock {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	var blocks []mdBlock
+	var current []string
+	kind := blockParagraph
+
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, mdBlock{kind: kind, text: strings.Join(current, "\n")})
+		}
+		current, kind = nil, blockParagraph
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence := trimmed[:3]
+			current, kind = []string{line}, blockCode
+			for i+1 < len(lines) {
+				i++
+				current = append(current, lines[i])
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+			}
+			flush()
+
+		case headingPattern.MatchString(line):
+			flush()
+			m := headingPattern.FindStringSubmatch(line)
+			blocks = append(blocks, mdBlock{kind: blockHeading, text: m[2], level: len(m[1])})
+
+		case strings.HasPrefix(trimmed, "|"):
+			if kind != blockTable {
+				flush()
+				kind = blockTable
+			}
+			current = append(current, line)
+
+		case listItemPattern.MatchString(line):
+			if kind != blockList {
+				flush()
+				kind = blockList
+			}
+			current = append(current, line)
+
+		default:
+			// Continuation lines stay with the list or table they follow
+			if kind == blockTable {
+				flush()
+			}
+			current = append(current, line)
+		}
+	}
+	flush()
+
+	return blocks
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownSplitter(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		size         int
+		want         []string
+		wantContexts []string
+	}{
+		{
+			name:         "sections carry their heading path",
+			text:         "# Guide\n\nIntro text.\n\n## Install\n\nRun the installer.\n\n## Use\n\nOpen the app.",
+			size:         100,
+			want:         []string{"Intro text.", "Run the installer.", "Open the app."},
+			wantContexts: []string{"Guide", "Guide > Install", "Guide > Use"},
+		},
+		{
+			name:         "a shallower heading closes the deeper ones",
+			text:         "# A\n\n## B\n\n### C\n\none\n\n## D\n\ntwo",
+			size:         100,
+			want:         []string{"one", "two"},
+			wantContexts: []string{"A > B > C", "A > D"},
+		},
+		{
+			name:         "blocks are packed up to size",
+			text:         "a b\n\nc d\n\ne f",
+			size:         4,
+			want:         []string{"a b\n\nc d", "e f"},
+			wantContexts: []string{"", ""},
+		},
+		{
+			name:         "code fences stay whole",
+			text:         "```\nx = 1\n\ny = 2\n```\n\nafter",
+			size:         100,
+			want:         []string{"```\nx = 1\n\ny = 2\n```\n\nafter"},
+			wantContexts: []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &MarkdownSplitter{Size: tt.size, Tokenizer: wordTokenizer{}}
+			chunks := s.Split(tt.text)
+			if got := texts(chunks); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Split() = %q, want %q", got, tt.want)
+			}
+			for i, c := range chunks {
+				if c.Context != tt.wantContexts[i] {
+					t.Errorf("chunk %d context = %q, want %q", i, c.Context, tt.wantContexts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMarkdownSplitterRepeatsTableHeader(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("| name | qty |\n| --- | --- |\n")
+	for i := 1; i <= 6; i++ {
+		fmt.Fprintf(&b, "| item%d | %d |\n", i, i)
+	}
+
+	// The header counts 10 words and every row 5, so two rows fit next to it
+	chunks := (&MarkdownSplitter{Size: 20, Tokenizer: wordTokenizer{}}).Split(b.String())
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3: %q", len(chunks), texts(chunks))
+	}
+	for i, c := range chunks {
+		if !strings.HasPrefix(c.Text, "| name | qty |\n| --- | --- |\n") {
+			t.Errorf("chunk %d doesn't start with the header: %q", i, c.Text)
+		}
+		if n := strings.Count(c.Text, "\n"); n != 3 {
+			t.Errorf("chunk %d has %d lines, want the header and 2 rows: %q", i, n+1, c.Text)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import "context"
+
+// SplitParents builds a small-to-big hierarchy: text is split into large
+// parent sections with parent, and every section into small child chunks with
+// child. Children are numbered across the whole text and point at their
+// section through Parent. They inherit the section's context unless the child
+// splitter set one, and the section's metadata under their own.
+func SplitParents(ctx context.Context, parent, child Splitter, text string) (parents, children []Chunk, err error) {
+	parents, err = SplitWith(ctx, parent, text)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, section := range parents {
+		parts, err := SplitWith(ctx, child, section.Text)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, part := range parts {
+			part.Index = len(children)
+			part.Parent = section.Index
+			if part.Context == "" {
+				part.Context = section.Context
+			}
+			part.Metadata = mergeMetadata(section.Metadata, part.Metadata)
+			children = append(children, part)
+		}
+	}
+	return parents, children, nil
+}
+
+// mergeMetadata returns base overlaid with override, reusing either map when the other is empty
+func mergeMetadata(base, override map[string]interface{}) map[string]interface{} {
+	if len(base) == 0 {
+		return override
+	}
+	if len(override) == 0 {
+		return base
+	}
+
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
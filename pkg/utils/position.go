@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Locate sets the Start and End character (rune) offsets of every chunk whose
+// text can be found in text. Chunks are searched for in order, each after the
+// start of the one before, so overlapping and repeated chunks are found where
+// they were cut. Chunks that got lines prepended while splitting, like table
+// chunks that repeat the header, are located by the rest of their text, and
+// are left unlocated if that can't be found either.
+func Locate(text string, chunks []Chunk) {
+	// byteFrom/runeFrom cache the rune offset of a byte position, so counting
+	// runes stays linear as long as the search moves forward
+	byteFrom, runeFrom := 0, 0
+	runeAt := func(b int) int {
+		if b < byteFrom {
+			byteFrom, runeFrom = 0, 0
+		}
+		runeFrom += utf8.RuneCountInString(text[byteFrom:b])
+		byteFrom = b
+		return runeFrom
+	}
+
+	cursor := 0
+	for i := range chunks {
+		start, end := find(text, chunks[i].Text, cursor)
+		if start < 0 {
+			continue
+		}
+		chunks[i].Start = runeAt(start)
+		chunks[i].End = chunks[i].Start + utf8.RuneCountInString(text[start:end])
+		cursor = start + 1
+	}
+}
+
+// maxPrefixLines is how many leading lines find drops looking for a chunk
+// that doesn't appear verbatim, enough for a repeated table header
+const maxPrefixLines = 2
+
+// find returns the byte range of chunk in text at or after from, without up to
+// maxPrefixLines leading lines when it doesn't appear verbatim. -1 if not found.
+func find(text, chunk string, from int) (int, int) {
+	if from > len(text) {
+		return -1, -1
+	}
+	chunk = strings.TrimSpace(chunk)
+	for skipped := 0; chunk != "" && skipped <= maxPrefixLines; skipped++ {
+		if i := strings.Index(text[from:], chunk); i >= 0 {
+			return from + i, from + i + len(chunk)
+		}
+
+		nl := strings.IndexByte(chunk, '\n')
+		if nl < 0 {
+			break
+		}
+		chunk = strings.TrimSpace(chunk[nl+1:])
+	}
+	return -1, -1
+}
+
+// SetPages records the pages each located chunk spans as <unit>_start and
+// <unit>_end metadata (1-based), given the character offset every page starts
+// at in the text the chunks were located in. unit is "page" or "slide".
+func SetPages(chunks []Chunk, pageStarts []int, unit string) {
+	if len(pageStarts) == 0 {
+		return
+	}
+
+	for i, chunk := range chunks {
+		if !chunk.Located() {
+			continue
+		}
+		chunks[i].Metadata = mergeMetadata(chunk.Metadata, map[string]interface{}{
+			unit + "_start": pageAt(pageStarts, chunk.Start),
+			unit + "_end":   pageAt(pageStarts, chunk.End-1),
+		})
+	}
+}
+
+// pageAt returns the 1-based page the offset falls on, pageStarts being sorted
+func pageAt(pageStarts []int, offset int) int {
+	return max(sort.SearchInts(pageStarts, offset+1), 1)
+}
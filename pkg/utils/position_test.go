@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		chunks []string
+		want   [][2]int // rune Start and End of each chunk, {0, 0} if not located
+	}{
+		{
+			name:   "in order",
+			text:   "one two. three four.",
+			chunks: []string{"one two.", "three four."},
+			want:   [][2]int{{0, 8}, {9, 20}},
+		},
+		{
+			name:   "offsets count runes, not bytes",
+			text:   "héllo wörld. done",
+			chunks: []string{"héllo wörld.", "done"},
+			want:   [][2]int{{0, 12}, {13, 17}},
+		},
+		{
+			name:   "repeated text is found where it was cut",
+			text:   "same. same. same.",
+			chunks: []string{"same.", "same.", "same."},
+			want:   [][2]int{{0, 5}, {6, 11}, {12, 17}},
+		},
+		{
+			name:   "overlapping chunks",
+			text:   "a b c d e",
+			chunks: []string{"a b c", "c d e"},
+			want:   [][2]int{{0, 5}, {4, 9}},
+		},
+		{
+			name:   "prepended header is skipped",
+			text:   "| h |\n| --- |\n| 1 |\n| 2 |",
+			chunks: []string{"| h |\n| --- |\n| 1 |", "| h |\n| --- |\n| 2 |"},
+			want:   [][2]int{{0, 19}, {20, 25}},
+		},
+		{
+			name:   "text that isn't there stays unlocated",
+			text:   "one two",
+			chunks: []string{"three", "two"},
+			want:   [][2]int{{0, 0}, {4, 7}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := make([]Chunk, len(tt.chunks))
+			for i, text := range tt.chunks {
+				chunks[i] = Chunk{Text: text, Index: i}
+			}
+			Locate(tt.text, chunks)
+
+			var got [][2]int
+			for _, c := range chunks {
+				got = append(got, [2]int{c.Start, c.End})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Locate() offsets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPages(t *testing.T) {
+	// Pages start at characters 0, 10 and 20
+	pageStarts := []int{0, 10, 20}
+	chunks := []Chunk{
+		{Text: "first", Start: 0, End: 5},
+		{Text: "across", Start: 8, End: 14},
+		{Text: "last", Start: 20, End: 24, Metadata: map[string]interface{}{"row_start": 3}},
+		{Text: "unlocated"},
+	}
+
+	SetPages(chunks, pageStarts, "slide")
+
+	want := []map[string]interface{}{
+		{"slide_start": 1, "slide_end": 1},
+		{"slide_start": 1, "slide_end": 2},
+		{"slide_start": 3, "slide_end": 3, "row_start": 3},
+		nil,
+	}
+	for i, c := range chunks {
+		if !reflect.DeepEqual(c.Metadata, want[i]) {
+			t.Errorf("chunk %d metadata = %v, want %v", i, c.Metadata, want[i])
+		}
+	}
+}
+
+func TestSetPagesWithoutPages(t *testing.T) {
+	chunks := []Chunk{{Text: "x", Start: 0, End: 1}}
+	SetPages(chunks, nil, "page")
+	if chunks[0].Metadata != nil {
+		t.Errorf("metadata = %v, want none without page starts", chunks[0].Metadata)
+	}
+}
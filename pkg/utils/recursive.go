@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Boundaries the recursive splitter tries, from the most to the least natural
+const (
+	levelParagraph = iota
+	levelLine
+	levelSentence
+	levelWord
+	levelRune
+)
+
+// RecursiveSplitter splits on paragraph breaks first, then on newlines, then
+// on sentence ends and finally on whitespace, only cutting through a word when
+// a single one is longer than Size. The pieces are packed back into chunks of
+// at most Size tokens, with up to Overlap tokens of whole pieces repeated
+// between neighbouring chunks. Tokens are counted with Tokenizer, the
+// ApproxTokenizer when nil.
+type RecursiveSplitter struct {
+	Size      int
+	Overlap   int
+	Tokenizer Tokenizer
+}
+
+func (s *RecursiveSplitter) Split(text string) []Chunk {
+	size, overlap := s.Size, s.Overlap
+	if size <= 0 {
+		size = 250
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	tok := tokenizerOr(s.Tokenizer)
+
+	var chunks []Chunk
+	for _, part := range mergePieces(splitRecursive(text, size, levelParagraph, tok), size, overlap, tok) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		chunks = append(chunks, Chunk{Text: part, Index: len(chunks)})
+	}
+	return chunks
+}
+
+// splitRecursive breaks text into pieces of at most size tokens, each cut made
+// at the most natural boundary available from level on
+func splitRecursive(text string, size, level int, tok Tokenizer) []string {
+	if tok.Count(text) <= size {
+		return []string{text}
+	}
+	if level == levelRune {
+		return splitToLimit(text, size, tok)
+	}
+
+	parts := splitAtLevel(text, level)
+	if len(parts) <= 1 {
+		return splitRecursive(text, size, level+1, tok)
+	}
+
+	var pieces []string
+	for _, part := range parts {
+		pieces = append(pieces, splitRecursive(part, size, level+1, tok)...)
+	}
+	return pieces
+}
+
+// splitAtLevel cuts text after every boundary of the level, so the parts
+// joined together give back the original text
+func splitAtLevel(text string, level int) []string {
+	switch level {
+	case levelParagraph:
+		return strings.SplitAfter(text, "\n\n")
+	case levelLine:
+		return strings.SplitAfter(text, "\n")
+	case levelSentence:
+		return splitSentences(text)
+	default:
+		return strings.SplitAfter(text, " ")
+	}
+}
+
+// splitSentences cuts after '.', '!' or '?' (and any closing quotes or
+// brackets) when followed by whitespace, keeping the whitespace on the left,
+// and after every CJK full stop
+func splitSentences(text string) []string {
+	var parts []string
+	start := 0
+	runes := []rune(text)
+	offset := 0 // byte offset of runes[i]
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		offset += utf8.RuneLen(r)
+		fullWidth := r == '。' || r == '！' || r == '？'
+		if r != '.' && r != '!' && r != '?' && !fullWidth {
+			continue
+		}
+
+		// Swallow closing punctuation, e.g. `end."` or `(see above.)`
+		end := offset
+		j := i + 1
+		for j < len(runes) && strings.ContainsRune(`"')]`+"”’", runes[j]) {
+			end += utf8.RuneLen(runes[j])
+			j++
+		}
+		// CJK full stops need no space after them
+		if !fullWidth && (j >= len(runes) || !unicode.IsSpace(runes[j])) {
+			continue
+		}
+		for j < len(runes) && unicode.IsSpace(runes[j]) {
+			end += utf8.RuneLen(runes[j])
+			j++
+		}
+
+		parts = append(parts, text[start:end])
+		start = end
+		offset = end
+		i = j - 1
+	}
+
+	if start < len(text) {
+		parts = append(parts, text[start:])
+	}
+	return parts
+}
+
+// splitRunes hard-cuts text every size runes, never inside a rune
+func splitRunes(text string, size int) []string {
+	runes := []rune(text)
+	var pieces []string
+	for i := 0; i < len(runes); i += size {
+		end := i + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		pieces = append(pieces, string(runes[i:end]))
+	}
+	return pieces
+}
+
+// mergePieces packs consecutive pieces into chunks of about size tokens.
+// Each new chunk starts with the trailing pieces of the previous one, as many
+// as fit in overlap tokens. Piece counts are summed, which can only overcount
+// the joined text by a token at each seam.
+func mergePieces(pieces []string, size, overlap int, tok Tokenizer) []string {
+	var chunks []string
+	var window []string
+	var counts []int
+	length := 0
+
+	for _, piece := range pieces {
+		n := tok.Count(piece)
+		if length+n > size && len(window) > 0 {
+			chunks = append(chunks, strings.Join(window, ""))
+
+			// Drop pieces from the front until what is left fits as overlap
+			// and leaves room for the new piece
+			for len(window) > 0 && (length > overlap || length+n > size) {
+				length -= counts[0]
+				window, counts = window[1:], counts[1:]
+			}
+		}
+
+		window = append(window, piece)
+		counts = append(counts, n)
+		length += n
+	}
+
+	if len(window) > 0 {
+		chunks = append(chunks, strings.Join(window, ""))
+	}
+	return chunks
+}
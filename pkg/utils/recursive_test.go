@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecursiveSplitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		size    int
+		overlap int
+		want    []string
+	}{
+		{
+			name: "fits in one chunk",
+			text: "one two three",
+			size: 5,
+			want: []string{"one two three"},
+		},
+		{
+			name: "paragraph breaks first",
+			text: "a b c\n\nd e f",
+			size: 3,
+			want: []string{"a b c", "d e f"},
+		},
+		{
+			name: "line breaks before sentences",
+			text: "a b. c d\ne f. g h",
+			size: 4,
+			want: []string{"a b. c d", "e f. g h"},
+		},
+		{
+			name: "sentence ends before words",
+			text: "One two. Three four. Five six.",
+			size: 2,
+			want: []string{"One two.", "Three four.", "Five six."},
+		},
+		{
+			name: "closing quotes stay with their sentence",
+			text: `He said "stop." Then left.`,
+			size: 3,
+			want: []string{`He said "stop."`, "Then left."},
+		},
+		{
+			name: "words when nothing else is left",
+			text: "a b c d e f g",
+			size: 3,
+			want: []string{"a b c", "d e f", "g"},
+		},
+		{
+			name:    "overlap repeats whole trailing pieces",
+			text:    "a b c d e f",
+			size:    3,
+			overlap: 1,
+			want:    []string{"a b c", "c d e", "e f"},
+		},
+		{
+			name:    "overlap as large as size is ignored",
+			text:    "a b c d",
+			size:    2,
+			overlap: 2,
+			want:    []string{"a b", "c d"},
+		},
+		{
+			name: "blank text gives no chunks",
+			text: " \n\n \n",
+			size: 3,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &RecursiveSplitter{Size: tt.size, Overlap: tt.overlap, Tokenizer: wordTokenizer{}}
+			chunks := s.Split(tt.text)
+			if got := texts(chunks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() = %q, want %q", got, tt.want)
+			}
+			for i, c := range chunks {
+				if c.Index != i {
+					t.Errorf("chunk %d has index %d", i, c.Index)
+				}
+			}
+		})
+	}
+}
+
+func TestRecursiveSplitterCutsLongWords(t *testing.T) {
+	// 40 letters are 10 approximate tokens, more than fit in one chunk
+	word := strings.Repeat("x", 40)
+	chunks := (&RecursiveSplitter{Size: 5}).Split(word)
+
+	if got := strings.Join(texts(chunks), ""); got != word {
+		t.Fatalf("chunks join to %q, want the word back", got)
+	}
+	for _, c := range chunks {
+		if n := (ApproxTokenizer{}).Count(c.Text); n > 5 {
+			t.Errorf("chunk %q counts %d tokens, limit is 5", c.Text, n)
+		}
+	}
+}
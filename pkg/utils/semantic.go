@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const defaultEmbedBatchSize = 100
+
+// Embedder turns texts into embedding vectors, one per text and in order
+type Embedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ContextSplitter is implemented by splitters that call out to other services
+// and can therefore be cancelled or fail
+type ContextSplitter interface {
+	Splitter
+	SplitContext(ctx context.Context, text string) ([]Chunk, error)
+}
+
+// SplitWith splits text with s, going through SplitContext when s supports it
+func SplitWith(ctx context.Context, s Splitter, text string) ([]Chunk, error) {
+	if cs, ok := s.(ContextSplitter); ok {
+		return cs.SplitContext(ctx, text)
+	}
+	return s.Split(text), nil
+}
+
+// SemanticSplitter embeds every sentence and starts a new chunk where the
+// cosine similarity between neighbouring sentences drops below the
+// Percentile-th percentile of all neighbour similarities in the text, so each
+// chunk sticks to one topic. Chunks are at least MinSize tokens unless the
+// text runs out, and never more than MaxSize.
+type SemanticSplitter struct {
+	Embedder   Embedder
+	Tokenizer  Tokenizer
+	MinSize    int
+	MaxSize    int
+	Percentile float64
+	BatchSize  int
+}
+
+// Split is SplitContext without a deadline. If embedding fails it falls back
+// to the recursive splitter, callers that can handle errors should use SplitWith.
+func (s *SemanticSplitter) Split(text string) []Chunk {
+	chunks, err := s.SplitContext(context.Background(), text)
+	if err != nil {
+		return (&RecursiveSplitter{Size: s.MaxSize, Tokenizer: s.Tokenizer}).Split(text)
+	}
+	return chunks
+}
+
+func (s *SemanticSplitter) SplitContext(ctx context.Context, text string) ([]Chunk, error) {
+	tok := tokenizerOr(s.Tokenizer)
+	maxSize := s.MaxSize
+	if maxSize <= 0 {
+		maxSize = 500
+	}
+	minSize := s.MinSize
+	if minSize < 0 || minSize > maxSize {
+		minSize = 0
+	}
+
+	sentences := sentencesOf(text, maxSize, tok)
+	if len(sentences) == 0 {
+		return nil, nil
+	}
+
+	embeddings, err := s.embed(ctx, sentences)
+	if err != nil {
+		return nil, err
+	}
+
+	// similarity[i] is between sentence i and sentence i+1
+	similarity := make([]float64, len(sentences)-1)
+	for i := range similarity {
+		similarity[i] = cosine(embeddings[i], embeddings[i+1])
+	}
+	threshold := percentile(similarity, s.Percentile)
+
+	var chunks []Chunk
+	var current []string
+	length := 0
+	flush := func() {
+		if part := strings.TrimSpace(strings.Join(current, "")); part != "" {
+			chunks = append(chunks, Chunk{Text: part, Index: len(chunks)})
+		}
+		current, length = nil, 0
+	}
+
+	for i, sentence := range sentences {
+		n := tok.Count(sentence)
+		if length+n > maxSize {
+			flush()
+		}
+		current = append(current, sentence)
+		length += n
+
+		if i < len(similarity) && similarity[i] < threshold && length >= minSize {
+			flush()
+		}
+	}
+	flush()
+
+	return chunks, nil
+}
+
+// embed embeds the sentences BatchSize at a time
+func (s *SemanticSplitter) embed(ctx context.Context, sentences []string) ([][]float32, error) {
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultEmbedBatchSize
+	}
+
+	embeddings := make([][]float32, 0, len(sentences))
+	for i := 0; i < len(sentences); i += batchSize {
+		end := min(i+batchSize, len(sentences))
+
+		batch, err := s.Embedder.EmbedBatch(ctx, sentences[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed sentences: %w", err)
+		}
+		if len(batch) != end-i {
+			return nil, fmt.Errorf("embedder returned %d vectors for %d sentences", len(batch), end-i)
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+// sentencesOf splits text into sentences, cutting any sentence longer than
+// maxSize tokens at word boundaries. Whitespace-only sentences are dropped.
+func sentencesOf(text string, maxSize int, tok Tokenizer) []string {
+	var sentences []string
+	for _, paragraph := range strings.SplitAfter(text, "\n\n") {
+		for _, sentence := range splitSentences(paragraph) {
+			for _, piece := range splitRecursive(sentence, maxSize, levelWord, tok) {
+				if strings.TrimSpace(piece) != "" {
+					sentences = append(sentences, piece)
+				}
+			}
+		}
+	}
+	return sentences
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentile returns the p-th percentile (0-100) of values, interpolating
+// between neighbours. With no values nothing is below it.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return math.Inf(-1)
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	p = math.Max(0, math.Min(100, p))
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
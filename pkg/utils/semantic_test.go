@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// topicEmbedder embeds a sentence on one axis per topic word it mentions, so
+// sentences about the same topic are identical and different topics orthogonal
+type topicEmbedder struct {
+	topics  []string
+	batches []int // size of every batch asked for
+	err     error
+}
+
+func (e *topicEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	e.batches = append(e.batches, len(texts))
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = make([]float32, len(e.topics))
+		for j, topic := range e.topics {
+			if strings.Contains(text, topic) {
+				out[i][j] = 1
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestSemanticSplitterBreaksBetweenTopics(t *testing.T) {
+	text := "Cats purr when happy. Cats nap all day. Stocks fell on Monday. Stocks rose again on Friday."
+	s := &SemanticSplitter{
+		Embedder:   &topicEmbedder{topics: []string{"Cats", "Stocks"}},
+		Tokenizer:  wordTokenizer{},
+		MaxSize:    100,
+		Percentile: 10,
+	}
+
+	chunks, err := SplitWith(context.Background(), s, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Cats purr when happy. Cats nap all day.", "Stocks fell on Monday. Stocks rose again on Friday."}
+	if got := texts(chunks); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitContext() = %q, want %q", got, want)
+	}
+}
+
+func TestSemanticSplitterSizes(t *testing.T) {
+	text := "Cats purr. Cats nap. Stocks fell. Stocks rose. Cats eat. Cats play."
+
+	t.Run("max size ends chunks within a topic", func(t *testing.T) {
+		s := &SemanticSplitter{Embedder: &topicEmbedder{topics: []string{"Cats", "Stocks"}}, Tokenizer: wordTokenizer{}, MaxSize: 2}
+		chunks, err := s.SplitContext(context.Background(), text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range chunks {
+			if n := (wordTokenizer{}).Count(c.Text); n > 2 {
+				t.Errorf("chunk %q has %d words, max is 2", c.Text, n)
+			}
+		}
+	})
+
+	t.Run("min size holds breakpoints back", func(t *testing.T) {
+		s := &SemanticSplitter{Embedder: &topicEmbedder{topics: []string{"Cats", "Stocks"}}, Tokenizer: wordTokenizer{}, MinSize: 6, MaxSize: 100, Percentile: 50}
+		chunks, err := s.SplitContext(context.Background(), text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The break after "Cats nap." would leave 4 words, the next one is taken instead
+		want := []string{"Cats purr. Cats nap. Stocks fell. Stocks rose.", "Cats eat. Cats play."}
+		if got := texts(chunks); !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitContext() = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestSemanticSplitterBatches(t *testing.T) {
+	embedder := &topicEmbedder{topics: []string{"a"}}
+	s := &SemanticSplitter{Embedder: embedder, Tokenizer: wordTokenizer{}, MaxSize: 100, BatchSize: 2}
+
+	if _, err := s.SplitContext(context.Background(), "One. Two. Three. Four. Five."); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 2, 1}; !reflect.DeepEqual(embedder.batches, want) {
+		t.Errorf("embedded in batches of %v, want %v", embedder.batches, want)
+	}
+}
+
+func TestSemanticSplitterEmbedFailure(t *testing.T) {
+	text := "One two. Three four."
+	s := &SemanticSplitter{
+		Embedder:  &topicEmbedder{err: errors.New("quota exceeded")},
+		Tokenizer: wordTokenizer{},
+		MaxSize:   2,
+	}
+
+	if _, err := SplitWith(context.Background(), s, text); err == nil {
+		t.Error("SplitWith() succeeded although embedding failed")
+	}
+
+	// Split can't fail and falls back to the recursive splitter
+	want := texts((&RecursiveSplitter{Size: 2, Tokenizer: wordTokenizer{}}).Split(text))
+	if got := texts(s.Split(text)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %q, want the recursive splitter's %q", got, want)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{0.4, 0.1, 0.3, 0.2, 0.5}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 0.1},
+		{12.5, 0.15},
+		{50, 0.3},
+		{100, 0.5},
+		{150, 0.5}, // clamped
+	}
+	for _, tt := range tests {
+		if got := percentile(values, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+
+	if got := percentile(nil, 10); !math.IsInf(got, -1) {
+		t.Errorf("percentile() of nothing = %v, want -Inf", got)
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import "fmt"
+
+// Splitter names accepted by NewSplitter
+const (
+	SplitterCharacter = "character"
+	SplitterRecursive = "recursive"
+	SplitterMarkdown  = "markdown"
+	// SplitterSemantic needs an Embedder, build a SemanticSplitter directly
+	SplitterSemantic = "semantic"
+)
+
+// Splitter breaks a document into chunks ready to be embedded
+type Splitter interface {
+	Split(text string) []Chunk
+}
+
+// NewSplitter returns the splitter registered under name, sized to chunkSize
+// tokens with overlap tokens repeated between chunks, as counted by tok.
+// The character splitter can't count tokens and assumes CharsPerToken instead.
+func NewSplitter(name string, chunkSize, overlap int, tok Tokenizer) (Splitter, error) {
+	switch name {
+	case SplitterCharacter:
+		return &CharacterSplitter{Size: chunkSize * CharsPerToken, Overlap: overlap * CharsPerToken}, nil
+	case SplitterRecursive, "":
+		return &RecursiveSplitter{Size: chunkSize, Overlap: overlap, Tokenizer: tok}, nil
+	case SplitterMarkdown:
+		return &MarkdownSplitter{Size: chunkSize, Overlap: overlap, Tokenizer: tok}, nil
+	default:
+		return nil, fmt.Errorf("unknown splitter: %s", name)
+	}
+}
+
+// CharacterSplitter cuts the text every Size runes, see SplitText
+type CharacterSplitter struct {
+	Size    int
+	Overlap int
+}
+
+func (s *CharacterSplitter) Split(text string) []Chunk {
+	return SplitText(text, s.Size, s.Overlap)
+}
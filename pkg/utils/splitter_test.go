@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// wordTokenizer counts whitespace separated words, so sizes in the tests are easy to follow
+type wordTokenizer struct{}
+
+func (wordTokenizer) Count(text string) int {
+	return len(strings.Fields(text))
+}
+
+func texts(chunks []Chunk) []string {
+	var out []string
+	for _, c := range chunks {
+		out = append(out, c.Text)
+	}
+	return out
+}
+
+func TestNewSplitter(t *testing.T) {
+	tests := []struct {
+		name string
+		want Splitter
+	}{
+		{name: "", want: &RecursiveSplitter{Size: 100, Overlap: 10, Tokenizer: wordTokenizer{}}},
+		{name: SplitterRecursive, want: &RecursiveSplitter{Size: 100, Overlap: 10, Tokenizer: wordTokenizer{}}},
+		{name: SplitterMarkdown, want: &MarkdownSplitter{Size: 100, Overlap: 10, Tokenizer: wordTokenizer{}}},
+		{name: SplitterCharacter, want: &CharacterSplitter{Size: 100 * CharsPerToken, Overlap: 10 * CharsPerToken}},
+	}
+
+	for _, tt := range tests {
+		got, err := NewSplitter(tt.name, 100, 10, wordTokenizer{})
+		if err != nil {
+			t.Fatalf("NewSplitter(%q) failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NewSplitter(%q) = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := NewSplitter("nope", 100, 10, wordTokenizer{}); err == nil {
+		t.Error("NewSplitter() accepted an unknown name")
+	}
+}
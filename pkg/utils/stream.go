@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"iter"
+	"unicode/utf8"
+)
+
+// StreamWindow is how many bytes of text StreamSplit holds at once by default
+const StreamWindow = 256 * 1024
+
+// StreamingSplitter is implemented by splitters that split text as they read
+// it, holding no more of it than the chunk being built needs
+type StreamingSplitter interface {
+	SplitReader(r io.Reader) iter.Seq2[Chunk, error]
+}
+
+// headingSplitter is implemented by splitters whose chunks carry the headings
+// above them, so StreamSplit can hand the heading path from window to window
+type headingSplitter interface {
+	splitUnder(path []string, text string) ([]Chunk, []string)
+}
+
+// StreamSplit yields the chunks of the text read from r without reading all of
+// it into memory. Streaming splitters split r themselves. Any other splitter is
+// run on consecutive windows of about window bytes, each cut at a heading,
+// paragraph, line or word boundary, so chunks never straddle two windows.
+// Chunks are numbered and located across the whole text, and markdown heading
+// paths carry over from one window into the next, overlap doesn't.
+func StreamSplit(ctx context.Context, s Splitter, r io.Reader, window int) iter.Seq2[Chunk, error] {
+	if ss, ok := s.(StreamingSplitter); ok {
+		return ss.SplitReader(r)
+	}
+	if window <= 0 {
+		window = StreamWindow
+	}
+
+	return func(yield func(Chunk, error) bool) {
+		buf := make([]byte, 0, window)
+		index, offset := 0, 0
+		eof := false
+		var path []string
+
+		for {
+			for len(buf) < window && !eof {
+				n, err := r.Read(buf[len(buf):window])
+				buf = buf[:len(buf)+n]
+				if err == io.EOF {
+					eof = true
+				} else if err != nil {
+					yield(Chunk{}, err)
+					return
+				}
+			}
+			if len(buf) == 0 {
+				return
+			}
+
+			cut := len(buf)
+			if !eof {
+				cut = windowCut(buf)
+			}
+			text := string(buf[:cut])
+
+			var chunks []Chunk
+			if hs, ok := s.(headingSplitter); ok {
+				chunks, path = hs.splitUnder(path, text)
+			} else {
+				var err error
+				if chunks, err = SplitWith(ctx, s, text); err != nil {
+					yield(Chunk{}, err)
+					return
+				}
+			}
+			Locate(text, chunks)
+
+			for _, chunk := range chunks {
+				chunk.Index = index
+				index++
+				if chunk.Located() {
+					chunk.Start += offset
+					chunk.End += offset
+				}
+				if !yield(chunk, nil) {
+					return
+				}
+			}
+
+			offset += utf8.RuneCount(buf[:cut])
+			buf = append(buf[:0], buf[cut:]...)
+		}
+	}
+}
+
+// windowCut picks where to end a full window: before the last heading or
+// after the last paragraph break, line break or space in its second half,
+// and otherwise at the end, backing off to the start of a rune
+func windowCut(buf []byte) int {
+	half := len(buf) / 2
+	if i := bytes.LastIndex(buf, []byte("\n#")); i > half {
+		return i + 1
+	}
+	for _, sep := range [][]byte{[]byte("\n\n"), []byte("\n"), []byte(" ")} {
+		if i := bytes.LastIndex(buf, sep); i > half {
+			return i + len(sep)
+		}
+	}
+
+	cut := len(buf)
+	for cut > 1 && !utf8.RuneStart(buf[cut-1]) {
+		cut--
+	}
+	if cut > 1 {
+		// buf[cut-1] starts a rune that may be incomplete
+		cut--
+	}
+	return cut
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// longDocument returns markdown with enough sections to span several stream windows
+func longDocument() string {
+	var b strings.Builder
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&b, "## Section %d\n\n", i)
+		for j := 1; j <= 3; j++ {
+			fmt.Fprintf(&b, "Paragraph %d of section %d talks about héllo wörld and little else. ", j, i)
+			b.WriteString("It goes on for a while so that chunks fill up.\n\n")
+		}
+	}
+	return b.String()
+}
+
+func TestStreamSplitMatchesSplit(t *testing.T) {
+	text := longDocument()
+	s := &RecursiveSplitter{Size: 30, Overlap: 5}
+
+	want, err := SplitWith(context.Background(), s, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Locate(text, want)
+
+	// A window larger than the text splits it in one go
+	var got []Chunk
+	for chunk, err := range StreamSplit(context.Background(), s, strings.NewReader(text), len(text)+1) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, chunk)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StreamSplit() gave %d chunks, Split %d, or they differ", len(got), len(want))
+	}
+}
+
+func TestStreamSplitLocatesChunksAcrossWindows(t *testing.T) {
+	text := longDocument()
+	runes := []rune(text)
+
+	for _, s := range []Splitter{
+		&RecursiveSplitter{Size: 30},
+		&MarkdownSplitter{Size: 30},
+	} {
+		t.Run(fmt.Sprintf("%T", s), func(t *testing.T) {
+			index := 0
+			for chunk, err := range StreamSplit(context.Background(), s, strings.NewReader(text), 1024) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if chunk.Index != index {
+					t.Errorf("chunk has index %d, want %d", chunk.Index, index)
+				}
+				index++
+
+				if !chunk.Located() {
+					t.Errorf("chunk %d is not located", chunk.Index)
+					continue
+				}
+				if got := string(runes[chunk.Start:chunk.End]); got != chunk.Text {
+					t.Errorf("chunk %d is located at %q, want %q", chunk.Index, got, chunk.Text)
+				}
+			}
+			if index < 2 {
+				t.Errorf("got %d chunks, want the text split up", index)
+			}
+		})
+	}
+}
+
+func TestStreamSplitCarriesHeadingPathAcrossWindows(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("# Guide\n\n## Install\n\n")
+	for i := 1; i <= 60; i++ {
+		fmt.Fprintf(&b, "Step %d of the install, run the next command and wait for it to finish.\n\n", i)
+	}
+	text := b.String()
+
+	chunks := 0
+	for chunk, err := range StreamSplit(context.Background(), &MarkdownSplitter{Size: 40}, strings.NewReader(text), 512) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks++
+		if chunk.Context != "Guide > Install" {
+			t.Errorf("chunk %d context = %q, want the heading path of the section", chunk.Index, chunk.Context)
+		}
+	}
+	if chunks < 2 {
+		t.Errorf("got %d chunks, want the text split up", chunks)
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// CharsPerToken is the rough number of characters of English prose per token,
+// used where a size in tokens has to become a size in characters
+const CharsPerToken = 4
+
+// Tokenizer counts the tokens a text takes up in the embedding model
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// ApproxTokenizer estimates token counts locally without the model's
+// vocabulary. Runs of letters and digits count as one token per 4 characters,
+// CJK characters, punctuation and symbols as one token each, and whitespace
+// as nothing. It errs on the high side for code and non-Latin scripts, where
+// a characters-based estimate is furthest off.
+type ApproxTokenizer struct{}
+
+func (ApproxTokenizer) Count(text string) int {
+	tokens, word := 0, 0
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			tokens += wordTokens(word) + 1
+			word = 0
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			word++
+		case unicode.IsSpace(r):
+			tokens += wordTokens(word)
+			word = 0
+		default:
+			tokens += wordTokens(word) + 1
+			word = 0
+		}
+	}
+	return tokens + wordTokens(word)
+}
+
+func wordTokens(runes int) int {
+	return (runes + CharsPerToken - 1) / CharsPerToken
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// tokenizerOr returns tok, or the ApproxTokenizer when tok is nil
+func tokenizerOr(tok Tokenizer) Tokenizer {
+	if tok == nil {
+		return ApproxTokenizer{}
+	}
+	return tok
+}
+
+// splitToLimit hard-cuts text into pieces of at most limit tokens, never
+// inside a rune. It cuts every limit runes first and halves any piece that
+// still counts too many tokens.
+func splitToLimit(text string, limit int, tok Tokenizer) []string {
+	var pieces []string
+	for _, piece := range splitRunes(text, limit) {
+		pieces = append(pieces, halveToLimit(piece, limit, tok)...)
+	}
+	return pieces
+}
+
+func halveToLimit(text string, limit int, tok Tokenizer) []string {
+	n := utf8.RuneCountInString(text)
+	if n <= 1 || tok.Count(text) <= limit {
+		return []string{text}
+	}
+	runes := []rune(text)
+	return append(halveToLimit(string(runes[:n/2]), limit, tok), halveToLimit(string(runes[n/2:]), limit, tok)...)
+}
+
+// FitToLimit re-splits every chunk whose embedded text (context included)
+// counts more than limit tokens, and renumbers the chunks. Split parts keep
+// the context, metadata and parent of the chunk they came from, and are located
+// within it when its offsets are known.
+func FitToLimit(chunks []Chunk, limit int, tok Tokenizer) []Chunk {
+	tok = tokenizerOr(tok)
+
+	var fitted []Chunk
+	for _, chunk := range chunks {
+		if tok.Count(chunk.EmbedText()) <= limit {
+			chunk.Index = len(fitted)
+			fitted = append(fitted, chunk)
+			continue
+		}
+
+		budget := limit
+		if chunk.Context != "" {
+			budget -= tok.Count(chunk.Context) + 1
+		}
+		if budget < 1 {
+			// The context alone is too long, drop it rather than the text
+			chunk.Context = ""
+			budget = limit
+		}
+
+		splitter := &RecursiveSplitter{Size: budget, Tokenizer: tok}
+		parts := splitter.Split(chunk.Text)
+		if chunk.Located() {
+			Locate(chunk.Text, parts)
+		}
+		for _, part := range parts {
+			if part.Located() {
+				part.Start += chunk.Start
+				part.End += chunk.Start
+			}
+			part.Index = len(fitted)
+			part.Context = chunk.Context
+			part.Metadata = chunk.Metadata
+			part.Parent = chunk.Parent
+			fitted = append(fitted, part)
+		}
+	}
+	return fitted
+}
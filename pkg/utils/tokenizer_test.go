@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApproxTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"   \n\t", 0},
+		{"word", 1},
+		{"words", 2},
+		{"two words", 3},
+		{"end.", 2},
+		{"日本語", 3},
+		{"x := 1", 4},
+	}
+
+	for _, tt := range tests {
+		if got := (ApproxTokenizer{}).Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFitToLimit(t *testing.T) {
+	tok := wordTokenizer{}
+
+	t.Run("chunks within the limit are kept and renumbered", func(t *testing.T) {
+		chunks := []Chunk{{Text: "a b", Index: 7}, {Text: "c", Index: 9}}
+		got := FitToLimit(chunks, 3, tok)
+		if len(got) != 2 || got[0].Text != "a b" || got[1].Text != "c" {
+			t.Fatalf("FitToLimit() = %q, want the chunks unchanged", texts(got))
+		}
+		if got[0].Index != 0 || got[1].Index != 1 {
+			t.Errorf("indexes = %d, %d, want 0, 1", got[0].Index, got[1].Index)
+		}
+	})
+
+	t.Run("long chunks are split and keep what they carried", func(t *testing.T) {
+		source := "intro a b c d e f g h outro"
+		chunk := Chunk{
+			Text:     "a b c d e f g h",
+			Context:  "Heading",
+			Metadata: map[string]interface{}{"heading_path": "Heading"},
+			Parent:   2,
+			Start:    6,
+			End:      21,
+		}
+		got := FitToLimit([]Chunk{{Text: "intro", Start: 0, End: 5}, chunk}, 4, tok)
+
+		// The context and the break after it leave two words for the text
+		want := []string{"intro", "a b", "c d", "e f", "g h"}
+		if g := texts(got); strings.Join(g, "|") != strings.Join(want, "|") {
+			t.Fatalf("FitToLimit() = %q, want %q", g, want)
+		}
+		runes := []rune(source)
+		for i, part := range got {
+			if part.Index != i {
+				t.Errorf("part %d has index %d", i, part.Index)
+			}
+			if n := tok.Count(part.EmbedText()); n > 4 {
+				t.Errorf("part %d counts %d tokens with its context, limit is 4", i, n)
+			}
+			if got := string(runes[part.Start:part.End]); got != part.Text {
+				t.Errorf("part %d is located at %q, want %q", i, got, part.Text)
+			}
+			if i == 0 {
+				continue
+			}
+			if part.Context != "Heading" || part.Metadata["heading_path"] != "Heading" || part.Parent != 2 {
+				t.Errorf("part %d lost its context, metadata or parent: %+v", i, part)
+			}
+		}
+	})
+
+	t.Run("a context longer than the limit is dropped", func(t *testing.T) {
+		chunk := Chunk{Text: "a b c d", Context: "one two three four five"}
+		got := FitToLimit([]Chunk{chunk}, 2, tok)
+		want := []string{"a b", "c d"}
+		if g := texts(got); strings.Join(g, "|") != strings.Join(want, "|") {
+			t.Fatalf("FitToLimit() = %q, want %q", g, want)
+		}
+		for i, part := range got {
+			if part.Context != "" {
+				t.Errorf("part %d kept context %q", i, part.Context)
+			}
+			if part.Located() {
+				t.Errorf("part %d is located though its chunk was not", i)
+			}
+		}
+	})
+}
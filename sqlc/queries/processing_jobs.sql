@@ -0,0 +1,54 @@
+-- name: CreateProcessingJob :one
+INSERT INTO processing_jobs (source_id, action, attempt)
+VALUES ($1, $2, $3)
+RETURNING id;
+
+-- name: UpdateProcessingJobStage :exec
+UPDATE processing_jobs
+SET stage = sqlc.arg(stage)::text,
+    stage_started_at = stage_started_at || jsonb_build_object(sqlc.arg(stage)::text, NOW())
+WHERE id = sqlc.arg(id);
+
+-- name: UpdateProcessingJobCounts :exec
+UPDATE processing_jobs
+SET chunk_count = $2, vector_count = $3, duplicate_count = $4
+WHERE id = $1;
+
+-- name: CompleteProcessingJob :exec
+UPDATE processing_jobs
+SET status = 'succeeded', finished_at = NOW()
+WHERE id = $1;
+
+-- name: SkipProcessingJob :exec
+UPDATE processing_jobs
+SET status = 'skipped', finished_at = NOW()
+WHERE id = $1;
+
+-- name: CancelProcessingJob :exec
+UPDATE processing_jobs
+SET status = 'cancelled', last_error = $2, finished_at = NOW()
+WHERE id = $1;
+
+-- name: FailProcessingJob :exec
+UPDATE processing_jobs
+SET status = 'failed', last_error = $2, finished_at = NOW()
+WHERE id = $1;
+
+-- name: RequestProcessingJobCancel :exec
+-- Recorded as a finished cancel job, so the request shows up in the source's
+-- history even when no job was running to stop
+INSERT INTO processing_jobs (source_id, action, status, cancel_requested_at, finished_at)
+SELECT id, 'cancel', 'cancelled', sqlc.arg(requested_at)::timestamptz, NOW()
+FROM sources
+WHERE id = sqlc.arg(source_id);
+
+-- name: HasProcessingJobCancelRequest :one
+SELECT EXISTS (
+    SELECT 1 FROM processing_jobs
+    WHERE source_id = $1 AND cancel_requested_at > $2
+);
+
+-- name: ListProcessingJobsBySourceID :many
+SELECT * FROM processing_jobs
+WHERE source_id = $1
+ORDER BY started_at DESC;
@@ -0,0 +1,24 @@
+-- name: ListSourceChunkManifests :many
+SELECT * FROM source_chunk_manifests
+WHERE source_id = $1
+ORDER BY chunk_index;
+
+-- name: UpsertSourceChunkManifests :exec
+INSERT INTO source_chunk_manifests (source_id, chunk_index, text_hash, meta_hash, simhash)
+SELECT sqlc.arg(source_id), unnest(sqlc.arg(chunk_indexes)::int[]), unnest(sqlc.arg(text_hashes)::text[]), unnest(sqlc.arg(meta_hashes)::text[]), unnest(sqlc.arg(simhashes)::bigint[])
+ON CONFLICT (source_id, chunk_index)
+DO UPDATE SET text_hash = EXCLUDED.text_hash, meta_hash = EXCLUDED.meta_hash, simhash = EXCLUDED.simhash, updated_at = NOW();
+
+-- name: ListUserChunkSimhashes :many
+-- Simhashes of every chunk the user has indexed from sources other than the given one, 0 means none
+SELECT m.simhash::bigint AS simhash
+FROM source_chunk_manifests m
+JOIN sources s ON s.id = m.source_id
+WHERE s.user_id = sqlc.arg(user_id) AND m.source_id <> sqlc.arg(source_id) AND m.simhash IS NOT NULL AND m.simhash <> 0;
+
+-- name: DeleteSourceChunkManifestsByIndex :exec
+DELETE FROM source_chunk_manifests
+WHERE source_id = sqlc.arg(source_id) AND chunk_index = ANY(sqlc.arg(chunk_indexes)::int[]);
+
+-- name: DeleteSourceChunkManifestsBySourceID :exec
+DELETE FROM source_chunk_manifests WHERE source_id = $1;
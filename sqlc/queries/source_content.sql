@@ -1,6 +1,57 @@
 -- name: CreateSourceContent :exec
-INSERT INTO source_contents (source_id, content_text)
-VALUES ($1, $2);
+-- version and byte_length are filled in by the source_contents_new_version trigger.
+-- Text that an older, no longer current version had moves that row up to a new
+-- version number and makes it current again, so A -> B -> A ends with A as the
+-- latest version. Saving the current text again changes nothing.
+INSERT INTO source_contents (source_id, content_text, content_hash, page_starts)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (source_id, content_hash)
+DO UPDATE SET version = EXCLUDED.version, content_text = EXCLUDED.content_text, byte_length = EXCLUDED.byte_length,
+    page_starts = EXCLUDED.page_starts, is_current = true, created_at = NOW()
+WHERE NOT source_contents.is_current;
+
+-- name: CreateEmptySourceContent :one
+-- CreateSourceContent for text streamed in with AppendSourceContent, in the same
+-- transaction. No row is returned when the text is already the current version.
+INSERT INTO source_contents (source_id, content_text, content_hash, page_starts)
+VALUES (sqlc.arg(source_id), '', sqlc.arg(content_hash), sqlc.arg(page_starts))
+ON CONFLICT (source_id, content_hash)
+DO UPDATE SET version = EXCLUDED.version, content_text = '', byte_length = 0,
+    page_starts = EXCLUDED.page_starts, is_current = true, created_at = NOW()
+WHERE NOT source_contents.is_current
+RETURNING id;
+
+-- name: AppendSourceContent :exec
+UPDATE source_contents
+SET content_text = content_text || sqlc.arg(piece)::text,
+    byte_length = byte_length + octet_length(sqlc.arg(piece)::text)
+WHERE id = sqlc.arg(id);
 
 -- name: GetSourceContentBySourceID :many
-SELECT * FROM source_contents WHERE source_id = $1;
\ No newline at end of file
+SELECT * FROM source_contents
+WHERE source_id = $1
+ORDER BY version DESC;
+
+-- name: GetLatestSourceContent :one
+SELECT * FROM source_contents
+WHERE source_id = $1
+ORDER BY is_current DESC, version DESC
+LIMIT 1;
+
+-- name: GetSourceContentByVersion :one
+SELECT * FROM source_contents
+WHERE source_id = $1 AND version = $2;
+
+-- name: ListSourceContentVersions :many
+SELECT id, source_id, version, content_hash, byte_length, is_current, created_at
+FROM source_contents
+WHERE source_id = $1
+ORDER BY version DESC;
+
+-- name: SetCurrentSourceContentVersion :exec
+UPDATE source_contents
+SET is_current = (version = $2)
+WHERE source_id = $1;
+
+-- name: DeleteSourceContentsBySourceID :exec
+DELETE FROM source_contents WHERE source_id = $1;
@@ -0,0 +1,17 @@
+-- name: GetSourceParentChunks :many
+SELECT * FROM source_parent_chunks
+WHERE source_id = sqlc.arg(source_id) AND parent_index = ANY(sqlc.arg(parent_indexes)::int[])
+ORDER BY parent_index;
+
+-- name: UpsertSourceParentChunks :exec
+INSERT INTO source_parent_chunks (source_id, parent_index, content, context)
+SELECT sqlc.arg(source_id), unnest(sqlc.arg(parent_indexes)::int[]), unnest(sqlc.arg(contents)::text[]), unnest(sqlc.arg(contexts)::text[])
+ON CONFLICT (source_id, parent_index)
+DO UPDATE SET content = EXCLUDED.content, context = EXCLUDED.context, updated_at = NOW();
+
+-- name: DeleteSourceParentChunksFrom :exec
+DELETE FROM source_parent_chunks
+WHERE source_id = $1 AND parent_index >= $2;
+
+-- name: DeleteSourceParentChunksBySourceID :exec
+DELETE FROM source_parent_chunks WHERE source_id = $1;
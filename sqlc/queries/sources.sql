@@ -11,4 +11,21 @@ WHERE id = $1;
 -- name: UpdateSourceTitleAndImage :exec
 UPDATE sources 
 SET title = $2, image_url = $3
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: UpdateSourceContentHash :exec
+UPDATE sources
+SET content_hash = $2
+WHERE id = $1;
+
+-- name: ListSourcesByUser :many
+SELECT id, user_id, collection_id, type, status, title, original_url, s3_bucket, s3_key, content_hash, created_at, image_url
+FROM sources
+WHERE user_id = $1
+ORDER BY created_at, id;
+
+-- name: ListSourcesByCollection :many
+SELECT id, user_id, collection_id, type, status, title, original_url, s3_bucket, s3_key, content_hash, created_at, image_url
+FROM sources
+WHERE collection_id = $1
+ORDER BY created_at, id;